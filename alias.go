@@ -0,0 +1,72 @@
+package jparser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrAliasCycle = errors.New("alias cycle")
+
+// DefineAlias registers path under name, so that a "$name" segment in a meta
+// path is replaced with path before parsing. Aliases may reference other aliases.
+func (o *Options) DefineAlias(name, path string) {
+	if o.Aliases == nil {
+		o.Aliases = make(map[string]string)
+	}
+
+	o.Aliases[name] = path
+}
+
+func expandMetaAliases(meta []MetaData, aliases map[string]string) ([]MetaData, error) {
+	if len(aliases) == 0 {
+		return meta, nil
+	}
+
+	res := make([]MetaData, len(meta))
+
+	for i, m := range meta {
+		path, err := expandAliases(m.Path, aliases, nil)
+		if err != nil {
+			return nil, &MetaError{m.ParamID, m.Path, err.Error(), err}
+		}
+
+		res[i] = m
+		res[i].Path = path
+	}
+
+	return res, nil
+}
+
+// expandAliases replaces every "$name" segment of path that names a defined
+// alias. Segments naming unknown aliases are kept as literal keys.
+func expandAliases(path string, aliases map[string]string, stack []string) (string, error) {
+	var segments []string
+
+	for rest := path; rest != ""; {
+		var segment string
+
+		segment, rest = splitPath(rest)
+
+		if name := strings.TrimPrefix(segment, "$"); name != segment {
+			if target, ok := aliases[name]; ok {
+				for _, s := range stack {
+					if s == name {
+						return "", fmt.Errorf("%w: %s", ErrAliasCycle, strings.Join(append(stack, name), " -> "))
+					}
+				}
+
+				expanded, err := expandAliases(target, aliases, append(stack, name))
+				if err != nil {
+					return "", err
+				}
+
+				segment = expanded
+			}
+		}
+
+		segments = append(segments, segment)
+	}
+
+	return strings.Join(segments, "."), nil
+}
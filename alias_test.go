@@ -0,0 +1,64 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestParseParamsAliases(t *testing.T) {
+	var opts jparser.Options
+
+	opts.DefineAlias("ul", "[].UL")
+	opts.DefineAlias("addr", "$ul.legalAddress.parsedAddressRF")
+
+	result, err := jparser.ParseParamsWithOptions(oneElementInArrayJSON, []jparser.MetaData{
+		{Path: "$addr.city.topoValue", ParamID: "city"},
+		{Path: "$addr.street.topoValue", ParamID: "street"},
+		{Path: "$ul.kpp", ParamID: "kpp"},
+		{Path: "[].$unknown", ParamID: "unknown"},
+	}, opts)
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		{
+			"city":   json.RawMessage(`"Екатеринбург"`),
+			"street": json.RawMessage(`"Народной воли"`),
+			"kpp":    json.RawMessage(`"667101001"`),
+		},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		got, _ := json.MarshalIndent(result, "", "  ")
+		expected, _ := json.MarshalIndent(expectedRes, "", "  ")
+		t.Errorf("ParseParamsWithOptions() got result = %s\nexpectedRes = %s", got, expected)
+	}
+}
+
+func TestParseParamsAliasCycle(t *testing.T) {
+	var opts jparser.Options
+
+	opts.DefineAlias("a", "[].$b")
+	opts.DefineAlias("b", "UL.$a")
+
+	result, err := jparser.ParseParamsWithOptions(oneElementInArrayJSON, []jparser.MetaData{
+		{Path: "$a.kpp", ParamID: "kpp"},
+	}, opts)
+	if !errors.Is(err, jparser.ErrAliasCycle) {
+		t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected ErrAliasCycle", err)
+	}
+
+	var metaErr *jparser.MetaError
+	if !errors.As(err, &metaErr) || metaErr.ParamID != "kpp" || metaErr.Path != "$a.kpp" {
+		t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected *MetaError for kpp at $a.kpp", err)
+	}
+
+	if result != nil {
+		t.Errorf("ParseParamsWithOptions() got result = %v, expected nil", result)
+	}
+}
@@ -0,0 +1,234 @@
+package jparser
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+)
+
+const (
+	groupParamID  = "group"
+	lengthParamID = "length"
+)
+
+// GroupByCount iterates the array at arrayPath and counts its elements by the
+// value of byField. String values are used unquoted, other values in their
+// canonical JSON form. Elements without byField are not counted.
+func GroupByCount(data json.RawMessage, arrayPath, byField string) (map[string]int, error) {
+	res, err := ParseParams(data, []MetaData{{Path: joinPath(arrayPath, "[]", byField), ParamID: groupParamID}})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+
+	for _, set := range res {
+		value, ok := set[groupParamID]
+		if !ok {
+			continue
+		}
+
+		key, err := groupKey(value)
+		if err != nil {
+			return nil, &UnmarshalError{err: err, paramID: groupParamID}
+		}
+
+		counts[key]++
+	}
+
+	return counts, nil
+}
+
+// ValueCount is a value with the number of times it occurred.
+type ValueCount struct {
+	Value json.RawMessage
+	Count int
+}
+
+// Histogram counts the values of field across the elements of the array at
+// arrayPath, like GroupByCount. Values are in canonical JSON form, sorted by
+// count descending, then by value.
+func Histogram(data json.RawMessage, arrayPath, field string) ([]ValueCount, error) {
+	res, err := ParseParams(data, []MetaData{{Path: joinPath(arrayPath, "[]", field), ParamID: groupParamID}})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+
+	for _, set := range res {
+		value, ok := set[groupParamID]
+		if !ok {
+			continue
+		}
+
+		canonical, err := canonicalJSON(value)
+		if err != nil {
+			return nil, &UnmarshalError{err: err, paramID: groupParamID}
+		}
+
+		counts[string(canonical)]++
+	}
+
+	histogram := make([]ValueCount, 0, len(counts))
+	for value, count := range counts {
+		histogram = append(histogram, ValueCount{json.RawMessage(value), count})
+	}
+
+	sort.Slice(histogram, func(i, j int) bool {
+		if histogram[i].Count != histogram[j].Count {
+			return histogram[i].Count > histogram[j].Count
+		}
+
+		return string(histogram[i].Value) < string(histogram[j].Value)
+	})
+
+	return histogram, nil
+}
+
+// LengthStats describes the distribution of array lengths. Percentiles use
+// the nearest-rank method.
+type LengthStats struct {
+	// Count is the number of arrays measured.
+	Count         int
+	Min, Max      int
+	Mean          float64
+	P50, P90, P99 int
+}
+
+// ArrayLengthStats measures the length of the array at arrayPath in every
+// document, or of every such array if the path iterates with "[]". Documents
+// without the array are skipped.
+func ArrayLengthStats(docs []json.RawMessage, arrayPath string) (LengthStats, error) {
+	meta := []MetaData{{Path: joinPath(arrayPath, "[]", "#"), ParamID: lengthParamID}}
+
+	var lengths []int
+
+	for _, data := range docs {
+		res, err := ParseParams(data, meta)
+		if err != nil {
+			return LengthStats{}, err
+		}
+
+		for _, set := range res {
+			value, ok := set[lengthParamID]
+			if !ok {
+				continue
+			}
+
+			length, err := strconv.Atoi(string(value))
+			if err != nil {
+				return LengthStats{}, &UnmarshalError{err: err, paramID: lengthParamID}
+			}
+
+			lengths = append(lengths, length)
+		}
+	}
+
+	return lengthStats(lengths), nil
+}
+
+func lengthStats(lengths []int) LengthStats {
+	if len(lengths) == 0 {
+		return LengthStats{}
+	}
+
+	sort.Ints(lengths)
+
+	sum := 0
+	for _, l := range lengths {
+		sum += l
+	}
+
+	percentile := func(p float64) int {
+		return lengths[int(math.Ceil(p*float64(len(lengths))))-1]
+	}
+
+	return LengthStats{
+		Count: len(lengths),
+		Min:   lengths[0],
+		Max:   lengths[len(lengths)-1],
+		Mean:  float64(sum) / float64(len(lengths)),
+		P50:   percentile(0.5),
+		P90:   percentile(0.9),
+		P99:   percentile(0.99),
+	}
+}
+
+// SetCount is a distinct result set with the number of times it occurred.
+type SetCount struct {
+	Set   RawMessageSet
+	Count int
+}
+
+// CountDistinctSets parses every document with meta and returns the distinct
+// result sets across all of them, in order of first occurrence. Sets holding
+// equal JSON values, regardless of formatting, are counted together.
+func CountDistinctSets(docs []json.RawMessage, meta []MetaData) ([]SetCount, error) {
+	p, err := NewParser(meta, Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	var res []SetCount
+
+	positions := make(map[string]int)
+
+	for _, data := range docs {
+		sets, err := p.ParseParams(data)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, set := range sets {
+			key, err := canonicalSet(set)
+			if err != nil {
+				return nil, err
+			}
+
+			if i, ok := positions[key]; ok {
+				res[i].Count++
+				continue
+			}
+
+			positions[key] = len(res)
+			res = append(res, SetCount{set, 1})
+		}
+	}
+
+	return res, nil
+}
+
+func groupKey(value json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s, nil
+	}
+
+	canonical, err := canonicalJSON(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(canonical), nil
+}
+
+// joinPath joins non-empty path segments with ".".
+func joinPath(segments ...string) string {
+	var res string
+
+	for _, s := range segments {
+		if s == "" {
+			continue
+		}
+
+		if res != "" {
+			res += "."
+		}
+
+		res += s
+	}
+
+	return res
+}
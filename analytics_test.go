@@ -0,0 +1,122 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestGroupByCount(t *testing.T) {
+	result, err := jparser.GroupByCount(oneElementInArrayJSON, "[].UL.branches", "parsedAddressRF.regionCode")
+	if err != nil {
+		t.Fatalf("GroupByCount() got error = \"%v\", expected nil", err)
+	}
+
+	expected := map[string]int{"77": 2, "78": 1, "59": 1, "74": 1}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("GroupByCount() got result = %v, expected %v", result, expected)
+	}
+
+	result, err = jparser.GroupByCount(multipleElementsInArrayJSON, "", "IP.status.dissolved")
+	if err != nil {
+		t.Fatalf("GroupByCount() got error = \"%v\", expected nil", err)
+	}
+
+	expected = map[string]int{"true": 2}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("GroupByCount() got result = %v, expected %v", result, expected)
+	}
+
+	if _, err := jparser.GroupByCount(oneObjectInJSON, "IP", "fio"); err == nil {
+		t.Errorf("GroupByCount() got error = nil for a non-array path, expected error")
+	}
+}
+
+func TestArrayLengthStats(t *testing.T) {
+	docs := []json.RawMessage{
+		json.RawMessage(`{"branches": [1, 2]}`),
+		json.RawMessage(`{"branches": []}`),
+		json.RawMessage(`{"inn": "6663003127"}`),
+		json.RawMessage(`{"branches": [1, 2, 3, 4]}`),
+		json.RawMessage(`{"branches": [1, 2, 3, 4, 5, 6, 7, 8, 9, 10]}`),
+	}
+
+	result, err := jparser.ArrayLengthStats(docs, "branches")
+	if err != nil {
+		t.Fatalf("ArrayLengthStats() got error = \"%v\", expected nil", err)
+	}
+
+	expected := jparser.LengthStats{Count: 4, Min: 0, Max: 10, Mean: 4, P50: 2, P90: 10, P99: 10}
+	if result != expected {
+		t.Errorf("ArrayLengthStats() got result = %+v, expected %+v", result, expected)
+	}
+
+	result, err = jparser.ArrayLengthStats([]json.RawMessage{oneElementInArrayJSON}, "[].UL.branches")
+	if err != nil {
+		t.Fatalf("ArrayLengthStats() got error = \"%v\", expected nil", err)
+	}
+
+	expected = jparser.LengthStats{Count: 1, Min: 5, Max: 5, Mean: 5, P50: 5, P90: 5, P99: 5}
+	if result != expected {
+		t.Errorf("ArrayLengthStats() got result = %+v, expected %+v", result, expected)
+	}
+}
+
+func TestCountDistinctSets(t *testing.T) {
+	docs := []json.RawMessage{
+		json.RawMessage(`[{"city": "Екатеринбург", "okfs": 16}, {"city": "Москва", "okfs": 16}]`),
+		json.RawMessage(`[{"city": "Москва", "okfs": 16.0}, {"city": "Москва", "okfs": 16}]`),
+		json.RawMessage(`[{"city": "Екатеринбург"}]`),
+		json.RawMessage(`[{ "okfs" : 16, "city" : "Екатеринбург" }]`),
+	}
+
+	meta := []jparser.MetaData{
+		{Path: "[].city", ParamID: "city"},
+		{Path: "[].okfs", ParamID: "okfs"},
+	}
+
+	result, err := jparser.CountDistinctSets(docs, meta)
+	if err != nil {
+		t.Fatalf("CountDistinctSets() got error = \"%v\", expected nil", err)
+	}
+
+	expected := []jparser.SetCount{
+		{Set: jparser.RawMessageSet{"city": json.RawMessage(`"Екатеринбург"`), "okfs": json.RawMessage(`16`)}, Count: 2},
+		{Set: jparser.RawMessageSet{"city": json.RawMessage(`"Москва"`), "okfs": json.RawMessage(`16`)}, Count: 2},
+		{Set: jparser.RawMessageSet{"city": json.RawMessage(`"Москва"`), "okfs": json.RawMessage(`16.0`)}, Count: 1},
+		{Set: jparser.RawMessageSet{"city": json.RawMessage(`"Екатеринбург"`)}, Count: 1},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("CountDistinctSets() got result = %v, expected %v", result, expected)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	result, err := jparser.Histogram(oneElementInArrayJSON, "[].UL.branches", "parsedAddressRF.regionCode")
+	if err != nil {
+		t.Fatalf("Histogram() got error = \"%v\", expected nil", err)
+	}
+
+	expected := []jparser.ValueCount{
+		{Value: json.RawMessage(`"77"`), Count: 2},
+		{Value: json.RawMessage(`"59"`), Count: 1},
+		{Value: json.RawMessage(`"74"`), Count: 1},
+		{Value: json.RawMessage(`"78"`), Count: 1},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Histogram() got result = %v, expected %v", result, expected)
+	}
+
+	result, err = jparser.Histogram(json.RawMessage(`{"items": [{"name": "A&B <x>"}, {"name": "A&B <x>"}]}`),
+		"items", "name")
+	if err != nil {
+		t.Fatalf("Histogram() got error = \"%v\", expected nil", err)
+	}
+
+	expected = []jparser.ValueCount{{Value: json.RawMessage(`"A&B <x>"`), Count: 2}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Histogram() got result = %v, expected %v", result, expected)
+	}
+}
@@ -0,0 +1,157 @@
+package jparser_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+var sharedPrefixMeta = []jparser.MetaData{
+	{Path: "[].UL.legalAddress.parsedAddressRF.zipCode", ParamID: "zip"},
+	{Path: "[].UL.legalAddress.parsedAddressRF.kladrCode", ParamID: "kladr"},
+	{Path: "[].UL.legalAddress.parsedAddressRF.regionCode", ParamID: "region_code"},
+	{Path: "[].UL.legalAddress.parsedAddressRF.regionName.topoValue", ParamID: "region"},
+	{Path: "[].UL.legalAddress.parsedAddressRF.city.topoValue", ParamID: "city"},
+	{Path: "[].UL.legalAddress.parsedAddressRF.street.topoValue", ParamID: "street"},
+	{Path: "[].UL.legalAddress.parsedAddressRF.bulk.topoValue", ParamID: "bulk"},
+	{Path: "[].UL.legalAddress.parsedAddressRF.bulkRaw", ParamID: "bulk_raw"},
+	{Path: "[].UL.legalAddress.parsedAddressRFFias.zipCode", ParamID: "fias_zip"},
+	{Path: "[].UL.legalAddress.parsedAddressRFFias.fiasId", ParamID: "fias_id"},
+	{Path: "[].UL.legalAddress.parsedAddressRFFias.city.topoValue", ParamID: "fias_city"},
+	{Path: "[].UL.legalAddress.parsedAddressRFFias.street.topoValue", ParamID: "fias_street"},
+	{Path: "[].UL.legalAddress.date", ParamID: "address_date"},
+	{Path: "[].UL.legalAddress.firstDate", ParamID: "address_first_date"},
+}
+
+func BenchmarkParseParamsSharedPrefix(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		opts jparser.Options
+	}{
+		{"memo", jparser.Options{}},
+		{"nomemo", jparser.Options{DisableMemoization: true}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := jparser.ParseParamsWithOptions(oneElementInArrayJSON, sharedPrefixMeta, bench.opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParseParamsPlain(b *testing.B) {
+	meta := make([]jparser.MetaData, len(sharedPrefixMeta))
+	for i, m := range sharedPrefixMeta {
+		m.Path = strings.TrimPrefix(m.Path, "[].")
+		meta[i] = m
+	}
+
+	var object []json.RawMessage
+	if err := json.Unmarshal(oneElementInArrayJSON, &object); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, bench := range []struct {
+		name      string
+		newParser func([]jparser.MetaData, jparser.Options) (*jparser.Parser, error)
+	}{
+		{"fast", jparser.NewParser},
+		{"general", jparser.NewGeneralPathParser},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			p, err := bench.newParser(meta, jparser.Options{})
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := p.ParseParams(object[0]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParseStream(b *testing.B) {
+	data := syntheticArray(10000)
+
+	p, err := jparser.NewParser(streamMeta, jparser.Options{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err := p.ParseStream(bytes.NewReader(data), func(jparser.RawMessageSet) error { return nil })
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseParamsRepeated(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := jparser.ParseParams(multipleElementsInArrayJSON, sharedPrefixMeta); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPlanParse(b *testing.B) {
+	plan, err := jparser.Compile(sharedPrefixMeta)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := plan.Parse(multipleElementsInArrayJSON); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseParamsIndependentArrays(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := jparser.ParseParams(productData, productMeta); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseParamsParallelism(b *testing.B) {
+	data := syntheticArray(10000)
+
+	for _, parallelism := range []int{1, 4} {
+		b.Run(fmt.Sprintf("Parallelism=%d", parallelism), func(b *testing.B) {
+			opts := jparser.Options{Parallelism: parallelism}
+
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := jparser.ParseParamsWithOptions(data, streamMeta, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
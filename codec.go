@@ -0,0 +1,57 @@
+package jparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// JSONCodec abstracts the JSON library used by the parser, so that
+// encoding/json can be replaced with a faster implementation. Scanning, as for
+// Options.OffsetSuffix and DetectDuplicateKeys, uses its decoders when they
+// also have the Token and InputOffset methods of *json.Decoder, and
+// encoding/json otherwise.
+type JSONCodec interface {
+	Unmarshal(data []byte, v interface{}) error
+	NewDecoder(r io.Reader) Decoder
+	Compact(dst *bytes.Buffer, src []byte) error
+}
+
+// Decoder reads successive JSON values from a stream.
+type Decoder interface {
+	Decode(v interface{}) error
+	More() bool
+}
+
+// StdCodec is the JSONCodec backed by encoding/json. It is used when
+// Options.Codec is nil.
+type StdCodec struct{}
+
+func (StdCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (StdCodec) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
+
+func (StdCodec) Compact(dst *bytes.Buffer, src []byte) error {
+	return json.Compact(dst, src)
+}
+
+// scanDecoder is a Decoder stepping through tokens and reporting its offset
+// in the input, as *json.Decoder does.
+type scanDecoder interface {
+	tokenDecoder
+	InputOffset() int64
+}
+
+// newScanDecoder returns a scanDecoder of data from codec, or from
+// encoding/json if the decoders of codec can't scan.
+func newScanDecoder(codec JSONCodec, data []byte) scanDecoder {
+	if dec, ok := codec.NewDecoder(bytes.NewReader(data)).(scanDecoder); ok {
+		return dec
+	}
+
+	return json.NewDecoder(bytes.NewReader(data))
+}
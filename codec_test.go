@@ -0,0 +1,168 @@
+package jparser_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+// countingCodec is a trivial JSONCodec delegating to StdCodec while counting
+// calls and recording the data unmarshaled.
+type countingCodec struct {
+	calls, decoders, compacts int
+	inputs                    []string
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v interface{}) error {
+	c.calls++
+	c.inputs = append(c.inputs, string(data))
+
+	return jparser.StdCodec{}.Unmarshal(data, v)
+}
+
+func (c *countingCodec) NewDecoder(r io.Reader) jparser.Decoder {
+	c.calls++
+	c.decoders++
+
+	return jparser.StdCodec{}.NewDecoder(r)
+}
+
+func (c *countingCodec) Compact(dst *bytes.Buffer, src []byte) error {
+	c.calls++
+	c.compacts++
+
+	return jparser.StdCodec{}.Compact(dst, src)
+}
+
+func TestCustomCodecIsUsed(t *testing.T) {
+	codec := &countingCodec{}
+
+	_, err := jparser.ParseParamsWithOptions(oneElementInArrayJSON, []jparser.MetaData{
+		{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+	}, jparser.Options{Codec: codec})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	if codec.calls == 0 {
+		t.Errorf("ParseParamsWithOptions() did not call the configured codec")
+	}
+}
+
+func TestCustomCodecCoversOptions(t *testing.T) {
+	testTable := []struct {
+		name     string
+		parse    func(jparser.JSONCodec) error
+		decoders bool
+		compacts bool
+		// input is data the codec must have unmarshaled.
+		input string
+	}{
+		{
+			name: "DetectDuplicateKeys",
+			parse: func(codec jparser.JSONCodec) error {
+				_, err := jparser.ParseParamsWithOptions(json.RawMessage(`{"inn": "1"}`),
+					[]jparser.MetaData{{Path: "inn", ParamID: "inn"}},
+					jparser.Options{Codec: codec, DetectDuplicateKeys: true})
+
+				return err
+			},
+			decoders: true,
+		},
+		{
+			name: "OffsetSuffix",
+			parse: func(codec jparser.JSONCodec) error {
+				_, err := jparser.ParseParamsWithOptions(json.RawMessage(`{"inn": "1"}`),
+					[]jparser.MetaData{{Path: "inn", ParamID: "inn"}},
+					jparser.Options{Codec: codec, OffsetSuffix: "_offset"})
+
+				return err
+			},
+			decoders: true,
+		},
+		{
+			name: "ExtractAll",
+			parse: func(codec jparser.JSONCodec) error {
+				_, err := jparser.ExtractAllWithCodec(json.RawMessage(`{"inn": "1"}`), codec)
+
+				return err
+			},
+		},
+		{
+			name: "Filter",
+			parse: func(codec jparser.JSONCodec) error {
+				_, err := jparser.ParseParamsWithOptions(json.RawMessage(`[{"kind": "a"}, {"kind": "b"}]`),
+					[]jparser.MetaData{{Path: `[?kind="c"].kind`, ParamID: "kind"}},
+					jparser.Options{Codec: codec})
+
+				return err
+			},
+			input: `"c"`,
+		},
+		{
+			name: "Schema",
+			parse: func(codec jparser.JSONCodec) error {
+				_, err := jparser.ParseParamsWithOptions(json.RawMessage(`{"inn": "1"}`),
+					[]jparser.MetaData{{Path: "inn", ParamID: "inn", Schema: json.RawMessage(`{"type": "string"}`)}},
+					jparser.Options{Codec: codec})
+
+				return err
+			},
+			input: `{"type": "string"}`,
+		},
+		{
+			name: "Error snippet",
+			parse: func(codec jparser.JSONCodec) error {
+				_, err := jparser.ParseParamsWithOptions(json.RawMessage(`{"inn": {"a": 1 }}`),
+					[]jparser.MetaData{{Path: "inn.[]", ParamID: "inn"}},
+					jparser.Options{Codec: codec})
+				if !errors.As(err, new(*jparser.UnmarshalError)) {
+					return fmt.Errorf("expected *UnmarshalError, got %w", err)
+				}
+
+				return nil
+			},
+			compacts: true,
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			codec := &countingCodec{}
+			if err := test.parse(codec); err != nil {
+				t.Fatalf("got error = \"%v\", expected nil", err)
+			}
+
+			if codec.calls == 0 {
+				t.Errorf("the configured codec was not called")
+			}
+
+			if test.decoders && codec.decoders == 0 {
+				t.Errorf("no decoder of the configured codec was used")
+			}
+
+			if test.compacts && codec.compacts == 0 {
+				t.Errorf("the configured codec did not compact")
+			}
+
+			if test.input != "" && !containsString(codec.inputs, test.input) {
+				t.Errorf("the configured codec was not given %s", test.input)
+			}
+		})
+	}
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+
+	return false
+}
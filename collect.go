@@ -0,0 +1,289 @@
+package jparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNotBoolean   = errors.New("value is not a boolean")
+	ErrMissingValue = errors.New("value is missing")
+)
+
+// aggregate reduces the values gathered from all array elements to one value.
+// missing is the number of elements the value wasn't found in.
+type aggregate func(values []json.RawMessage, missing int, strict bool) (json.RawMessage, error)
+
+// aggregates are applied with a "~name" suffix on a path below "[]", e.g.
+// "[].UL.branches.[].parsedAddressRF.regionCode~distinctcount".
+var aggregates = map[string]aggregate{
+	"distinctcount": distinctCount,
+	"any":           anyTrue,
+	"all":           allTrue,
+}
+
+// collect gathers the values of every param from all array elements into a
+// single JSON array per param. Values keep the order of the source array.
+func (p *parser) collect(n *node, g *group) ([]RawMessageSet, error) {
+	meta := g.meta
+
+	if err := p.checkContainer(n, meta, "array"); err != nil {
+		return nil, err
+	}
+
+	sliceJSON, err := p.array(n)
+	if err != nil {
+		return nil, p.unmarshalError(err, meta[0].ParamID, n)
+	}
+
+	collected, present, err := p.gather(n, sliceJSON, g.next)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range meta {
+		if _, ok := collected[m.ParamID]; !ok {
+			if err := p.checkRequired([]MetaData{m}, n.child(nil, "[*]")); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	res := p.presence(meta, present, len(sliceJSON))
+
+	for paramID, values := range collected {
+		if p.opts.ReverseCollect {
+			for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+				values[i], values[j] = values[j], values[i]
+			}
+		}
+
+		res[paramID] = joinArray(values)
+	}
+
+	return []RawMessageSet{res}, nil
+}
+
+// gather extracts the level lvl from every element of the array n and returns the
+// values found for each param in source order, along with the number of
+// elements each param was found in.
+func (p *parser) gather(
+	n *node, sliceJSON []json.RawMessage, lvl *level,
+) (collected map[string][]json.RawMessage, present map[string]int, err error) {
+	collected = make(map[string][]json.RawMessage, len(lvl.meta))
+	present = make(map[string]int, len(lvl.meta))
+
+	// The gathered values are needed even when validating only.
+	if p.discard {
+		values := *p
+		values.discard = false
+		p = &values
+	}
+
+	for i, JSON := range sliceJSON {
+		if err := p.canceled(); err != nil {
+			return nil, nil, err
+		}
+
+		element := n.child(JSON, indexSegment(i))
+		if err := p.checkElementDepth(element); err != nil {
+			return nil, nil, err
+		}
+
+		currentRes, err := p.parseParams(element, lvl)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		found := make(map[string]bool, len(lvl.meta))
+
+		for _, set := range currentRes {
+			for paramID, value := range set {
+				collected[paramID] = append(collected[paramID], value)
+				found[paramID] = true
+			}
+		}
+
+		for paramID := range found {
+			present[paramID]++
+		}
+	}
+
+	return collected, present, nil
+}
+
+// presence returns a set with the presence companion of every param in
+// meta when Options.PresenceSuffix is set, or an empty set otherwise.
+func (p *parser) presence(meta []MetaData, present map[string]int, total int) RawMessageSet {
+	res := RawMessageSet{}
+
+	if p.opts.PresenceSuffix == "" {
+		return res
+	}
+
+	for _, m := range meta {
+		res[m.ParamID+p.opts.PresenceSuffix] = json.RawMessage(
+			`{"present":` + strconv.Itoa(present[m.ParamID]) + `,"total":` + strconv.Itoa(total) + `}`)
+	}
+
+	return res
+}
+
+// aggregate reduces meta entries carrying a "~name" suffix over the array n,
+// binding a single value per param.
+func (p *parser) aggregate(n *node, sliceJSON []json.RawMessage, aggs []*aggregateGroup) ([]RawMessageSet, error) {
+	res := RawMessageSet{}
+
+	for _, a := range aggs {
+		m, name := a.meta, a.name
+
+		collected, present, err := p.gather(n, sliceJSON, a.next)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range p.presence([]MetaData{m}, present, len(sliceJSON)) {
+			res[k] = v
+		}
+
+		missing := len(sliceJSON) - present[m.ParamID]
+
+		value, err := aggregates[name](collected[m.ParamID], missing, p.opts.StrictBoolAggregates)
+		if err != nil {
+			return nil, &UnmarshalError{err: err, paramID: m.ParamID}
+		}
+
+		res[m.ParamID] = value
+	}
+
+	return []RawMessageSet{res}, nil
+}
+
+// splitAggregates separates meta entries with a known "~name" suffix from the
+// rest. An aggregate reduces over the last "[]" of its path, so entries with
+// another "[]" ahead are left for the nested level.
+func splitAggregates(meta []MetaData) (metaBase, metaAggregate []MetaData) {
+	for _, m := range meta {
+		if path, _, ok := splitAggregate(m.Path); ok && !hasSegment(path, "[]") {
+			metaAggregate = append(metaAggregate, m)
+		} else {
+			metaBase = append(metaBase, m)
+		}
+	}
+
+	return metaBase, metaAggregate
+}
+
+func splitAggregate(path string) (restOfPath, name string, ok bool) {
+	i := strings.LastIndexByte(path, '~')
+	if i < 0 || (i > 0 && path[i-1] == '\\') ||
+		strings.HasPrefix(path[strings.LastIndexByte(path, '.')+1:], literalKey) {
+		return path, "", false
+	}
+
+	if _, ok := aggregates[path[i+1:]]; !ok {
+		return path, "", false
+	}
+
+	return path[:i], path[i+1:], true
+}
+
+func hasSegment(path, segment string) bool {
+	for path != "" {
+		var currentPath string
+
+		currentPath, path = splitPath(path)
+		if currentPath == segment {
+			return true
+		}
+	}
+
+	return false
+}
+
+func distinctCount(values []json.RawMessage, _ int, _ bool) (json.RawMessage, error) {
+	distinct := make(map[string]struct{}, len(values))
+
+	for _, v := range values {
+		canonical, err := canonicalJSON(v)
+		if err != nil {
+			return nil, err
+		}
+
+		distinct[string(canonical)] = struct{}{}
+	}
+
+	return json.RawMessage(strconv.Itoa(len(distinct))), nil
+}
+
+func anyTrue(values []json.RawMessage, missing int, strict bool) (json.RawMessage, error) {
+	bools, err := booleans(values, missing, strict)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range bools {
+		if b {
+			return json.RawMessage("true"), nil
+		}
+	}
+
+	return json.RawMessage("false"), nil
+}
+
+func allTrue(values []json.RawMessage, missing int, strict bool) (json.RawMessage, error) {
+	bools, err := booleans(values, missing, strict)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range bools {
+		if !b {
+			return json.RawMessage("false"), nil
+		}
+	}
+
+	return json.RawMessage("true"), nil
+}
+
+// booleans decodes values, skipping non-boolean ones unless strict is set.
+// In strict mode missing values are an error as well.
+func booleans(values []json.RawMessage, missing int, strict bool) ([]bool, error) {
+	if strict && missing > 0 {
+		return nil, fmt.Errorf("%w in %d elements", ErrMissingValue, missing)
+	}
+
+	res := make([]bool, 0, len(values))
+
+	for _, v := range values {
+		if jsonType(v) != "boolean" {
+			if strict {
+				return nil, fmt.Errorf("%w: %s", ErrNotBoolean, v)
+			}
+
+			continue
+		}
+
+		res = append(res, bytes.Equal(bytes.TrimSpace(v), []byte("true")))
+	}
+
+	return res, nil
+}
+
+func joinArray(values []json.RawMessage) json.RawMessage {
+	res := []byte{'['}
+
+	for i, v := range values {
+		if i > 0 {
+			res = append(res, ',')
+		}
+
+		res = append(res, v...)
+	}
+
+	return append(res, ']')
+}
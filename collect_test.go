@@ -0,0 +1,293 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestParseParamsAggregates(t *testing.T) {
+	testTable := []struct {
+		name        string
+		args        args
+		expectedRes []jparser.RawMessageSet
+	}{
+		{
+			name: "Distinct region codes across branches",
+			args: args{
+				data: oneElementInArrayJSON,
+				meta: []jparser.MetaData{
+					{Path: "[].inn", ParamID: "inn"},
+					{Path: "[].UL.branches.[].parsedAddressRF.regionCode~distinctcount", ParamID: "regions"},
+					{Path: "[].UL.branches.[].#", ParamID: "branches"},
+				},
+			},
+			expectedRes: []jparser.RawMessageSet{
+				{
+					"inn":      json.RawMessage(`"6663003127"`),
+					"regions":  json.RawMessage(`4`),
+					"branches": json.RawMessage(`5`),
+				},
+			},
+		},
+		{
+			name: "Distinct values are compared canonically",
+			args: args{
+				data: json.RawMessage(`[{"v": {"a": 1, "b": 2}}, {"v": {"b":2,"a":1}}, {"v": 1}, {}]`),
+				meta: []jparser.MetaData{
+					{Path: "[].v~distinctcount", ParamID: "distinct"},
+				},
+			},
+			expectedRes: []jparser.RawMessageSet{
+				{"distinct": json.RawMessage(`2`)},
+			},
+		},
+	}
+
+	for _, codec := range testCodecs {
+		for _, test := range testTable {
+			t.Run(codec.name+"/"+test.name, func(t *testing.T) {
+				result, err := jparser.ParseParamsWithOptions(test.args.data, test.args.meta,
+					jparser.Options{Codec: codec.codec})
+				if err != nil {
+					t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+					return
+				}
+
+				if !reflect.DeepEqual(result, test.expectedRes) {
+					got, _ := json.MarshalIndent(result, "", "  ")
+					expected, _ := json.MarshalIndent(test.expectedRes, "", "  ")
+					t.Errorf("ParseParamsWithOptions() got result = %s\nexpectedRes = %s", got, expected)
+				}
+			})
+		}
+	}
+}
+
+func TestParseParamsCollect(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "[].UL.branches.[*].kpp", ParamID: "kpps"},
+		{Path: "[].inn", ParamID: "inn"},
+	}
+
+	testTable := []struct {
+		name        string
+		opts        jparser.Options
+		expectedRes []jparser.RawMessageSet
+	}{
+		{
+			name: "Source order",
+			opts: jparser.Options{},
+			expectedRes: []jparser.RawMessageSet{
+				{
+					"inn":  json.RawMessage(`"6663003127"`),
+					"kpps": json.RawMessage(`["771543001","771543002","780243001","590443001","745343002"]`),
+				},
+			},
+		},
+		{
+			name: "Reversed order",
+			opts: jparser.Options{ReverseCollect: true},
+			expectedRes: []jparser.RawMessageSet{
+				{
+					"inn":  json.RawMessage(`"6663003127"`),
+					"kpps": json.RawMessage(`["745343002","590443001","780243001","771543002","771543001"]`),
+				},
+			},
+		},
+	}
+
+	for _, codec := range testCodecs {
+		for _, test := range testTable {
+			t.Run(codec.name+"/"+test.name, func(t *testing.T) {
+				opts := test.opts
+				opts.Codec = codec.codec
+
+				result, err := jparser.ParseParamsWithOptions(oneElementInArrayJSON, meta, opts)
+				if err != nil {
+					t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+					return
+				}
+
+				if !reflect.DeepEqual(result, test.expectedRes) {
+					got, _ := json.MarshalIndent(result, "", "  ")
+					expected, _ := json.MarshalIndent(test.expectedRes, "", "  ")
+					t.Errorf("ParseParamsWithOptions() got result = %s\nexpectedRes = %s", got, expected)
+				}
+			})
+		}
+	}
+}
+
+func TestParseParamsCollectPerElement(t *testing.T) {
+	data := json.RawMessage(`[
+		{"inn": "6663003127", "UL": {"branches": [{"kpp": "771543001"}, {"name": "Филиал"}, {"kpp": "780243001"}]}},
+		{"inn": "7452160483", "UL": {"branches": []}},
+		{"inn": "772473497153"}
+	]`)
+
+	result, err := jparser.ParseParams(data, []jparser.MetaData{
+		{Path: "[].inn", ParamID: "inn"},
+		{Path: "[].UL.branches.[*].kpp", ParamID: "kpps"},
+	})
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		{"inn": json.RawMessage(`"6663003127"`), "kpps": json.RawMessage(`["771543001","780243001"]`)},
+		{"inn": json.RawMessage(`"7452160483"`)},
+		{"inn": json.RawMessage(`"772473497153"`)},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		got, _ := json.MarshalIndent(result, "", "  ")
+		expected, _ := json.MarshalIndent(expectedRes, "", "  ")
+		t.Errorf("ParseParams() got result = %s\nexpectedRes = %s", got, expected)
+	}
+}
+
+func TestParseParamsPresence(t *testing.T) {
+	result, err := jparser.ParseParamsWithOptions(oneElementInArrayJSON, []jparser.MetaData{
+		{Path: "[].UL.branches.[*].name", ParamID: "names"},
+		{Path: "[].UL.branches.[].parsedAddressRF.flat.topoValue~distinctcount", ParamID: "flats"},
+	}, jparser.Options{PresenceSuffix: "_presence"})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		{
+			"names":          json.RawMessage(`["ПЕРМСКИЙ ФИЛИАЛ АО \"ПФ \"СКБ КОНТУР\""]`),
+			"names_presence": json.RawMessage(`{"present":1,"total":5}`),
+			"flats":          json.RawMessage(`3`),
+			"flats_presence": json.RawMessage(`{"present":3,"total":5}`),
+		},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		got, _ := json.MarshalIndent(result, "", "  ")
+		expected, _ := json.MarshalIndent(expectedRes, "", "  ")
+		t.Errorf("ParseParamsWithOptions() got result = %s\nexpectedRes = %s", got, expected)
+	}
+}
+
+func TestParseParamsBoolAggregates(t *testing.T) {
+	testTable := []struct {
+		name        string
+		args        args
+		expectedRes []jparser.RawMessageSet
+	}{
+		{
+			name: "Sparse boolean in branches",
+			args: args{
+				data: oneElementInArrayJSON,
+				meta: []jparser.MetaData{
+					{Path: "[].UL.branches.[].parsedAddressRF.isConverted~any", ParamID: "any_converted"},
+					{Path: "[].UL.branches.[].parsedAddressRF.isConverted~all", ParamID: "all_converted"},
+				},
+			},
+			expectedRes: []jparser.RawMessageSet{
+				{
+					"any_converted": json.RawMessage(`true`),
+					"all_converted": json.RawMessage(`true`),
+				},
+			},
+		},
+		{
+			name: "Missing values are skipped",
+			args: args{
+				data: multipleElementsInArrayJSON,
+				meta: []jparser.MetaData{
+					{Path: "[].briefReport.summary.greenStatements~any", ParamID: "any_green"},
+					{Path: "[].briefReport.summary.greenStatements~all", ParamID: "all_green"},
+					{Path: "[].IP.status.dissolved~any", ParamID: "any_dissolved"},
+					{Path: "[].IP.status.dissolved~all", ParamID: "all_dissolved"},
+				},
+			},
+			expectedRes: []jparser.RawMessageSet{
+				{
+					"any_green":     json.RawMessage(`true`),
+					"all_green":     json.RawMessage(`true`),
+					"any_dissolved": json.RawMessage(`true`),
+					"all_dissolved": json.RawMessage(`true`),
+				},
+			},
+		},
+		{
+			name: "Mixed and non-boolean values",
+			args: args{
+				data: json.RawMessage(`[{"flag": false}, {"flag": true}, {"flag": null}]`),
+				meta: []jparser.MetaData{
+					{Path: "[].flag~any", ParamID: "any"},
+					{Path: "[].flag~all", ParamID: "all"},
+					{Path: "[].other~any", ParamID: "any_other"},
+					{Path: "[].other~all", ParamID: "all_other"},
+				},
+			},
+			expectedRes: []jparser.RawMessageSet{
+				{
+					"any":       json.RawMessage(`true`),
+					"all":       json.RawMessage(`false`),
+					"any_other": json.RawMessage(`false`),
+					"all_other": json.RawMessage(`true`),
+				},
+			},
+		},
+	}
+
+	for _, codec := range testCodecs {
+		for _, test := range testTable {
+			t.Run(codec.name+"/"+test.name, func(t *testing.T) {
+				result, err := jparser.ParseParamsWithOptions(test.args.data, test.args.meta,
+					jparser.Options{Codec: codec.codec})
+				if err != nil {
+					t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+					return
+				}
+
+				if !reflect.DeepEqual(result, test.expectedRes) {
+					got, _ := json.MarshalIndent(result, "", "  ")
+					expected, _ := json.MarshalIndent(test.expectedRes, "", "  ")
+					t.Errorf("ParseParamsWithOptions() got result = %s\nexpectedRes = %s", got, expected)
+				}
+			})
+		}
+	}
+}
+
+func TestParseParamsStrictBoolAggregates(t *testing.T) {
+	testTable := []struct {
+		name        string
+		data        json.RawMessage
+		expectedErr error
+	}{
+		{
+			name:        "Missing value",
+			data:        oneElementInArrayJSON,
+			expectedErr: jparser.ErrMissingValue,
+		},
+		{
+			name:        "Non-boolean value",
+			data:        json.RawMessage(`[{"UL": {"branches": [{"parsedAddressRF": {"isConverted": "yes"}}]}}]`),
+			expectedErr: jparser.ErrNotBoolean,
+		},
+	}
+
+	for _, codec := range testCodecs {
+		for _, test := range testTable {
+			t.Run(codec.name+"/"+test.name, func(t *testing.T) {
+				_, err := jparser.ParseParamsWithOptions(test.data, []jparser.MetaData{
+					{Path: "[].UL.branches.[].parsedAddressRF.isConverted~any", ParamID: "any_converted"},
+				}, jparser.Options{StrictBoolAggregates: true, Codec: codec.codec})
+				var unmarshalErr *jparser.UnmarshalError
+				if !errors.As(err, &unmarshalErr) || !errors.Is(unmarshalErr.Err(), test.expectedErr) {
+					t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected %v", err, test.expectedErr)
+				}
+			})
+		}
+	}
+}
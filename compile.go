@@ -0,0 +1,285 @@
+package jparser
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// level is the precompiled form of the meta applied to one JSON node: the
+// entries grouped by the first segment of their paths, so that paths are
+// split once rather than on every document. Groups keep the order their
+// segment first appears in, which fixes the order of the result sets.
+type level struct {
+	meta []MetaData
+	// leaves are the entries whose paths end at the node, bound to it whole.
+	leaves []MetaData
+	groups []*group
+	// plain is set if the groups below the level all look up object keys,
+	// so that it always yields a single set, see fill.
+	plain bool
+}
+
+// group holds the meta entries sharing the first path segment, with the
+// segment stripped from their paths.
+type group struct {
+	segment string
+	meta    []MetaData
+	// path is the meta path up to and including segment, see label.
+	path string
+	// name is the object key looked up by a key segment.
+	name string
+	// element is the array index selected by an "[N]" segment, negative
+	// from the end.
+	element *int
+	// next is the level below a key, "[N]", "**" or "[*]" segment.
+	next *level
+	// The rest is set for segments iterating an array, see iterates,
+	// splitMeta and splitAggregates.
+	filter                                  *filter
+	window                                  *window
+	base                                    *level
+	all, index, key, count, singleton, last *MetaData
+	aggregates                              []*aggregateGroup
+}
+
+// aggregateGroup is a meta entry reduced over "[]" by a "~name" aggregate.
+type aggregateGroup struct {
+	meta MetaData
+	name string
+	next *level
+}
+
+func compile(meta []MetaData) *level {
+	lvl := &level{meta: meta}
+
+	currentPathToGroup := make(map[string]*group)
+	for i := 0; i < len(meta); i++ {
+		if meta[i].Path == "" {
+			lvl.leaves = append(lvl.leaves, meta[i])
+
+			continue
+		}
+
+		currentPath, restOfPath := splitPath(meta[i].Path)
+		newMeta := meta[i]
+		newMeta.Path = restOfPath
+
+		g, ok := currentPathToGroup[currentPath]
+		if !ok {
+			g = &group{segment: currentPath}
+			currentPathToGroup[currentPath] = g
+			lvl.groups = append(lvl.groups, g)
+		}
+
+		g.meta = append(g.meta, newMeta)
+	}
+
+	lvl.plain = len(meta) > 0
+
+	for _, g := range lvl.groups {
+		g.compile()
+
+		lvl.plain = lvl.plain && g.container() == "object" && !g.iterates() && g.next.plain
+	}
+
+	return lvl
+}
+
+func (g *group) compile() {
+	g.filter = parseFilter(g.segment)
+	g.window = parseWindow(g.segment)
+
+	if i, ok := parseIndex(g.segment); ok {
+		g.element = &i
+	}
+
+	switch {
+	case g.segment == "&now" || isValueToken(g.segment):
+	case g.iterates():
+		var metaBase []MetaData
+
+		metaBase, g.all, g.index, g.key, g.count, g.singleton, g.last = splitMeta(g.meta)
+		metaBase, metaAggregate := splitAggregates(metaBase)
+
+		g.base = compile(metaBase)
+
+		for _, m := range metaAggregate {
+			path, name, _ := splitAggregate(m.Path)
+			m.Path = path
+
+			g.aggregates = append(g.aggregates, &aggregateGroup{m, name, compile([]MetaData{m})})
+		}
+	case g.element != nil:
+		g.next = compile(g.meta)
+	case g.segment == "^":
+		g.next = compile(g.meta)
+	case g.segment == "**":
+		// Required and Default are checked once for the whole descent.
+		meta := make([]MetaData, len(g.meta))
+		for i, m := range g.meta {
+			m.Required, m.Default = false, nil
+			meta[i] = m
+		}
+
+		g.next = compile(meta)
+	default:
+		g.name = unescapeKey(strings.TrimPrefix(g.segment, literalKey))
+		g.next = compile(g.meta)
+	}
+}
+
+// label sets the meta path of the groups below lvl, found at path.
+func (lvl *level) label(path string) *level {
+	for _, g := range lvl.groups {
+		g.path = joinPath(path, g.segment)
+
+		for _, next := range g.levels() {
+			next.label(g.path)
+		}
+	}
+
+	return lvl
+}
+
+// isValueToken reports whether segment is a token binding a property of the
+// node it applies to: "#" its length, "&type" its JSON type, "&keys" its
+// keys and "&exists" its presence, see existsDefaults.
+func isValueToken(segment string) bool {
+	switch segment {
+	case "#", "&type", "&keys", "&exists":
+		return true
+	}
+
+	return false
+}
+
+// existsDefaults defaults the entries of meta ending with "&exists" to false,
+// so that a path that doesn't resolve binds false instead of nothing.
+func existsDefaults(meta []MetaData) []MetaData {
+	var res []MetaData
+
+	for i, m := range meta {
+		if m.Default != nil || lastSegment(m.Path) != "&exists" {
+			continue
+		}
+
+		if res == nil {
+			res = append([]MetaData{}, meta...)
+		}
+
+		res[i].Default = json.RawMessage("false")
+	}
+
+	if res == nil {
+		return meta
+	}
+
+	return res
+}
+
+// lastSegment returns the last segment of path.
+func lastSegment(path string) string {
+	var segment string
+
+	for path != "" {
+		segment, path = splitPath(path)
+	}
+
+	return segment
+}
+
+// iterates reports whether g iterates array elements like "[]", or object
+// values for "*".
+func (g *group) iterates() bool {
+	return g.segment == "[]" || g.segment == "*" || g.filter != nil || g.window != nil
+}
+
+// window is a "[start:end]" segment, which iterates the elements of an array
+// from start up to end like "[]". Either bound may be omitted, negative
+// bounds count from the end, and bounds are clamped to the array.
+type window struct {
+	start, end       int
+	hasStart, hasEnd bool
+}
+
+// parseWindow returns the window of segment, or nil if it isn't one.
+func parseWindow(segment string) *window {
+	if !strings.HasPrefix(segment, "[") || !strings.HasSuffix(segment, "]") {
+		return nil
+	}
+
+	start, end, ok := strings.Cut(segment[1:len(segment)-1], ":")
+	if !ok {
+		return nil
+	}
+
+	var (
+		w   window
+		err error
+	)
+
+	if w.hasStart = start != ""; w.hasStart {
+		if w.start, err = strconv.Atoi(start); err != nil {
+			return nil
+		}
+	}
+
+	if w.hasEnd = end != ""; w.hasEnd {
+		if w.end, err = strconv.Atoi(end); err != nil {
+			return nil
+		}
+	}
+
+	return &w
+}
+
+// bounds returns the clamped bounds of w over an array of length elements.
+func (w *window) bounds(length int) (start, end int) {
+	start, end = 0, length
+
+	if w.hasStart {
+		start = clamp(w.start, length)
+	}
+
+	if w.hasEnd {
+		end = clamp(w.end, length)
+	}
+
+	if start > end {
+		start = end
+	}
+
+	return start, end
+}
+
+func clamp(i, length int) int {
+	if i < 0 {
+		i += length
+	}
+
+	if i < 0 {
+		return 0
+	}
+
+	if i > length {
+		return length
+	}
+
+	return i
+}
+
+// parseIndex returns N of an "[N]" segment. A negative N counts from the end
+// of the array.
+func parseIndex(segment string) (int, bool) {
+	if !strings.HasPrefix(segment, "[") || !strings.HasSuffix(segment, "]") {
+		return 0, false
+	}
+
+	i, err := strconv.Atoi(segment[1 : len(segment)-1])
+	if err != nil {
+		return 0, false
+	}
+
+	return i, true
+}
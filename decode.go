@@ -0,0 +1,225 @@
+package jparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DecodeOptions tunes the behaviour of the Decode* helpers.
+type DecodeOptions struct {
+	// UseNumber decodes numbers into interface{} values as json.Number
+	// instead of float64, keeping their exact textual representation.
+	UseNumber bool
+}
+
+// PrecisionError reports a number that can't be represented exactly by the
+// Go type it was decoded into, e.g. a large int64 decoded into float64.
+type PrecisionError struct {
+	ParamID string
+	Value   json.RawMessage
+}
+
+func (e *PrecisionError) Error() string {
+	return fmt.Sprintf("error: number %s loses precision on decode, param_id: %s", e.Value, e.ParamID)
+}
+
+// DecodeResult holds the outcome of decoding a single RawMessageSet.
+// Exactly one of Value and Err is set.
+type DecodeResult struct {
+	Value interface{}
+	Err   error
+}
+
+// DecodeSets decodes every set into a fresh value returned by newValue
+// (usually a pointer to a struct or a map). A set that fails to decode does
+// not stop the batch: its error is reported in the corresponding DecodeResult.
+func DecodeSets(sets []RawMessageSet, newValue func() interface{}) []DecodeResult {
+	return DecodeSetsWithOptions(sets, newValue, DecodeOptions{})
+}
+
+func DecodeSetsWithOptions(sets []RawMessageSet, newValue func() interface{}, opts DecodeOptions) []DecodeResult {
+	res := make([]DecodeResult, len(sets))
+
+	for i, set := range sets {
+		v := newValue()
+
+		if err := decodeSet(set, v, opts); err != nil {
+			res[i] = DecodeResult{Err: err}
+			continue
+		}
+
+		res[i] = DecodeResult{Value: v}
+	}
+
+	return res
+}
+
+// DecodeSet decodes set into v, usually a pointer to a struct, treating
+// ParamIDs as field names the way encoding/json matches object keys, json tags
+// included. Fields without a param in set are left untouched, so a fresh
+// value keeps their zero values. A value of the wrong type is an error.
+func DecodeSet(set RawMessageSet, v interface{}) error {
+	return decodeSet(set, v, DecodeOptions{})
+}
+
+// AsString returns the string value of paramID in set. It reports false if
+// the param is missing or isn't a JSON string.
+func AsString(set RawMessageSet, paramID string) (string, bool) {
+	value, ok := set[paramID]
+	if !ok || !bytes.HasPrefix(bytes.TrimSpace(value), []byte(`"`)) {
+		return "", false
+	}
+
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return "", false
+	}
+
+	return s, true
+}
+
+// AsBool returns the boolean value of paramID in set. It reports false if the
+// param is missing or isn't a JSON boolean.
+func AsBool(set RawMessageSet, paramID string) (bool, bool) {
+	value, ok := set[paramID]
+	if !ok || jsonType(value) != "boolean" {
+		return false, false
+	}
+
+	var b bool
+	if err := json.Unmarshal(value, &b); err != nil {
+		return false, false
+	}
+
+	return b, true
+}
+
+// AsInt64 returns the integer value of paramID in set. It reports false if
+// the param is missing, isn't a JSON number, has a fraction or exponent, or
+// overflows int64.
+func AsInt64(set RawMessageSet, paramID string) (int64, bool) {
+	value, ok := set[paramID]
+	if !ok || jsonType(value) != "number" {
+		return 0, false
+	}
+
+	i, err := strconv.ParseInt(string(bytes.TrimSpace(value)), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return i, true
+}
+
+// AsFloat64 returns the number value of paramID in set. It reports false if
+// the param is missing, isn't a JSON number or overflows float64.
+func AsFloat64(set RawMessageSet, paramID string) (float64, bool) {
+	value, ok := set[paramID]
+	if !ok || jsonType(value) != "number" {
+		return 0, false
+	}
+
+	f, err := strconv.ParseFloat(string(bytes.TrimSpace(value)), 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return f, true
+}
+
+func decodeSet(set RawMessageSet, v interface{}, opts DecodeOptions) error {
+	data, err := json.Marshal(set)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if opts.UseNumber {
+		dec.UseNumber()
+	}
+
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+
+	return checkPrecision(set, v)
+}
+
+// checkPrecision encodes the decoded value back and compares every number
+// with its source literal, so that any lossy conversion is reported.
+func checkPrecision(set RawMessageSet, v interface{}) error {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var decoded map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil // nolint:nilerr // not an object, nothing to compare with
+	}
+
+	paramIDs := make([]string, 0, len(set))
+	for paramID := range set {
+		paramIDs = append(paramIDs, paramID)
+	}
+
+	sort.Strings(paramIDs)
+
+	for _, paramID := range paramIDs {
+		source, ok := parseNumber(set[paramID])
+		if !ok {
+			continue
+		}
+
+		_, value, ok := lookupKey(decoded, paramID)
+		if !ok {
+			continue
+		}
+
+		if target, ok := parseNumber(value); ok && source.Cmp(target) != 0 {
+			return &PrecisionError{paramID, set[paramID]}
+		}
+	}
+
+	return nil
+}
+
+// lookupKey finds key the way encoding/json matches object keys to struct
+// fields: an exact match is preferred over a case-insensitive one. Among
+// several case-insensitive matches, the first in sorted order wins. It
+// returns the matching key of m.
+func lookupKey(m map[string]json.RawMessage, key string) (string, json.RawMessage, bool) {
+	if v, ok := m[key]; ok {
+		return key, v, true
+	}
+
+	var match string
+
+	found := false
+
+	for k := range m {
+		if strings.EqualFold(k, key) && (!found || k < match) {
+			match, found = k, true
+		}
+	}
+
+	if !found {
+		return key, nil, false
+	}
+
+	return match, m[match], true
+}
+
+func parseNumber(data json.RawMessage) (*big.Rat, bool) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || (data[0] != '-' && (data[0] < '0' || data[0] > '9')) {
+		return nil, false
+	}
+
+	return new(big.Rat).SetString(string(data))
+}
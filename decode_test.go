@@ -0,0 +1,273 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+type branch struct {
+	Kpp   string `json:"kpp"`
+	Count int    `json:"count"`
+}
+
+func TestDecodeSets(t *testing.T) {
+	sets := []jparser.RawMessageSet{
+		{"kpp": json.RawMessage(`"771543001"`), "count": json.RawMessage(`1`)},
+		{"kpp": json.RawMessage(`"771543002"`), "count": json.RawMessage(`"two"`)},
+		{"kpp": json.RawMessage(`"780243001"`), "count": json.RawMessage(`3`)},
+	}
+
+	res := jparser.DecodeSets(sets, func() interface{} { return &branch{} })
+
+	if len(res) != len(sets) {
+		t.Fatalf("DecodeSets() got %d results, expected %d", len(res), len(sets))
+	}
+
+	expected := []*branch{{"771543001", 1}, nil, {"780243001", 3}}
+
+	for i, r := range res {
+		if expected[i] == nil {
+			if r.Err == nil || r.Value != nil {
+				t.Errorf("DecodeSets()[%d] got value = %v, error = %v, expected error", i, r.Value, r.Err)
+			}
+
+			continue
+		}
+
+		if r.Err != nil {
+			t.Errorf("DecodeSets()[%d] got error = \"%v\", expected nil", i, r.Err)
+			continue
+		}
+
+		if !reflect.DeepEqual(r.Value, expected[i]) {
+			t.Errorf("DecodeSets()[%d] got value = %+v, expected %+v", i, r.Value, expected[i])
+		}
+	}
+}
+
+func TestDecodeSetsPrecision(t *testing.T) {
+	sets := []jparser.RawMessageSet{{"value": json.RawMessage(`9007199254740993`)}}
+
+	testTable := []struct {
+		name        string
+		newValue    func() interface{}
+		opts        jparser.DecodeOptions
+		expectedErr bool
+	}{
+		{
+			name:     "int64",
+			newValue: func() interface{} { return &struct{ Value int64 }{} },
+		},
+		{
+			name:     "uint64",
+			newValue: func() interface{} { return &struct{ Value uint64 }{} },
+		},
+		{
+			name:        "int32 overflow",
+			newValue:    func() interface{} { return &struct{ Value int32 }{} },
+			expectedErr: true,
+		},
+		{
+			name:        "float64",
+			newValue:    func() interface{} { return &struct{ Value float64 }{} },
+			expectedErr: true,
+		},
+		{
+			name:        "float32",
+			newValue:    func() interface{} { return &struct{ Value float32 }{} },
+			expectedErr: true,
+		},
+		{
+			name:        "interface",
+			newValue:    func() interface{} { return &map[string]interface{}{} },
+			expectedErr: true,
+		},
+		{
+			name:     "interface with UseNumber",
+			newValue: func() interface{} { return &map[string]interface{}{} },
+			opts:     jparser.DecodeOptions{UseNumber: true},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			res := jparser.DecodeSetsWithOptions(sets, test.newValue, test.opts)[0]
+
+			if test.expectedErr {
+				if res.Err == nil {
+					t.Errorf("DecodeSetsWithOptions() got error = nil, expected error")
+				}
+
+				return
+			}
+
+			if res.Err != nil {
+				t.Errorf("DecodeSetsWithOptions() got error = \"%v\", expected nil", res.Err)
+			}
+		})
+	}
+
+	res := jparser.DecodeSets(sets, func() interface{} { return &struct{ Value float64 }{} })[0]
+
+	var precisionErr *jparser.PrecisionError
+	if !errors.As(res.Err, &precisionErr) || precisionErr.ParamID != "value" {
+		t.Errorf("DecodeSets() got error = \"%v\", expected *PrecisionError for \"value\"", res.Err)
+	}
+}
+
+func TestAsString(t *testing.T) {
+	set := jparser.RawMessageSet{
+		"kpp":   json.RawMessage(`"77\"15а"`),
+		"count": json.RawMessage(`1`),
+		"date":  json.RawMessage(`null`),
+	}
+
+	testTable := []struct {
+		paramID    string
+		expected   string
+		expectedOK bool
+	}{
+		{paramID: "kpp", expected: `77"15а`, expectedOK: true},
+		{paramID: "count"},
+		{paramID: "date"},
+		{paramID: "missing"},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.paramID, func(t *testing.T) {
+			s, ok := jparser.AsString(set, testCase.paramID)
+			if s != testCase.expected || ok != testCase.expectedOK {
+				t.Errorf("AsString() got = %q, %v, expected %q, %v", s, ok, testCase.expected, testCase.expectedOK)
+			}
+		})
+	}
+}
+
+func TestAsBool(t *testing.T) {
+	result, err := jparser.ParseParams(oneObjectInJSON, []jparser.MetaData{
+		{Path: "briefReport.summary.greenStatements", ParamID: "green"},
+		{Path: "inn", ParamID: "inn"},
+	})
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	set := result[0]
+	set["dissolved"] = json.RawMessage(`false`)
+
+	testTable := []struct {
+		paramID    string
+		expected   bool
+		expectedOK bool
+	}{
+		{paramID: "green", expected: true, expectedOK: true},
+		{paramID: "dissolved", expectedOK: true},
+		{paramID: "inn"},
+		{paramID: "missing"},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.paramID, func(t *testing.T) {
+			b, ok := jparser.AsBool(set, testCase.paramID)
+			if b != testCase.expected || ok != testCase.expectedOK {
+				t.Errorf("AsBool() got = %v, %v, expected %v, %v", b, ok, testCase.expected, testCase.expectedOK)
+			}
+		})
+	}
+}
+
+func TestAsNumber(t *testing.T) {
+	result, err := jparser.ParseParams(oneElementInArrayJSON, []jparser.MetaData{
+		{Path: "[].contactPhones.count", ParamID: "phones"},
+		{Path: "[].UL.branches.#", ParamID: "branches"},
+		{Path: "[].inn", ParamID: "inn"},
+	})
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	set := result[0]
+	set["share"] = json.RawMessage(`0.25`)
+	set["capital"] = json.RawMessage(`1e400`)
+	set["overflow"] = json.RawMessage(`9223372036854775808`)
+
+	testTable := []struct {
+		paramID         string
+		expectedInt     int64
+		expectedIntOK   bool
+		expectedFloat   float64
+		expectedFloatOK bool
+	}{
+		{paramID: "phones", expectedInt: 77, expectedIntOK: true, expectedFloat: 77, expectedFloatOK: true},
+		{paramID: "branches", expectedInt: 5, expectedIntOK: true, expectedFloat: 5, expectedFloatOK: true},
+		{paramID: "share", expectedFloat: 0.25, expectedFloatOK: true},
+		{paramID: "overflow", expectedFloat: 9223372036854775808, expectedFloatOK: true},
+		{paramID: "capital"},
+		{paramID: "inn"},
+		{paramID: "missing"},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.paramID, func(t *testing.T) {
+			i, ok := jparser.AsInt64(set, testCase.paramID)
+			if i != testCase.expectedInt || ok != testCase.expectedIntOK {
+				t.Errorf("AsInt64() got = %v, %v, expected %v, %v", i, ok, testCase.expectedInt, testCase.expectedIntOK)
+			}
+
+			f, ok := jparser.AsFloat64(set, testCase.paramID)
+			if f != testCase.expectedFloat || ok != testCase.expectedFloatOK {
+				t.Errorf("AsFloat64() got = %v, %v, expected %v, %v",
+					f, ok, testCase.expectedFloat, testCase.expectedFloatOK)
+			}
+		})
+	}
+}
+
+func TestDecodeSet(t *testing.T) {
+	type dated struct {
+		Kpp  string `json:"kpp"`
+		Date string
+	}
+
+	testTable := []struct {
+		name        string
+		set         jparser.RawMessageSet
+		expected    dated
+		expectedErr bool
+	}{
+		{
+			name:     "full set",
+			set:      jparser.RawMessageSet{"kpp": json.RawMessage(`"771543001"`), "Date": json.RawMessage(`"2020-01-01"`)},
+			expected: dated{Kpp: "771543001", Date: "2020-01-01"},
+		},
+		{
+			name:     "partial set",
+			set:      jparser.RawMessageSet{"kpp": json.RawMessage(`"771543001"`)},
+			expected: dated{Kpp: "771543001"},
+		},
+		{
+			name:        "type mismatch",
+			set:         jparser.RawMessageSet{"kpp": json.RawMessage(`771543001`)},
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			var v dated
+
+			err := jparser.DecodeSet(testCase.set, &v)
+			if (err != nil) != testCase.expectedErr {
+				t.Fatalf("DecodeSet() got error = \"%v\", expected error = %v", err, testCase.expectedErr)
+			}
+
+			if !testCase.expectedErr && v != testCase.expected {
+				t.Errorf("DecodeSet() got value = %+v, expected %+v", v, testCase.expected)
+			}
+		})
+	}
+}
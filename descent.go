@@ -0,0 +1,122 @@
+package jparser
+
+import (
+	"errors"
+	"sort"
+)
+
+// defaultMaxDescentDepth bounds "**" when Options.MaxDescentDepth is zero.
+const defaultMaxDescentDepth = 100
+
+// descend extracts the "**" group g from n and every value nested in it, in
+// document order with object keys sorted, producing one row per value where
+// any param resolves. Required and Default apply only when none does. With
+// Options.FirstOnly, the walk stops at the first row.
+func (p *parser) descend(n *node, g *group) ([]RawMessageSet, error) {
+	lenient := *p
+	lenient.discard = false
+	lenient.opts.Strict = false
+	lenient.opts.ProvenanceSuffix = ""
+	lenient.errs = nil
+
+	var res []RawMessageSet
+
+	if err := lenient.walk(n, g, 0, &res); err != nil {
+		return nil, err
+	}
+
+	if len(res) == 0 {
+		if err := p.checkRequired(g.meta, n.child(nil, g.segment)); err != nil {
+			return nil, err
+		}
+
+		return []RawMessageSet{p.unresolved(g.meta, n)}, nil
+	}
+
+	return res, nil
+}
+
+func (p *parser) walk(n *node, g *group, depth int, res *[]RawMessageSet) error {
+	maxDepth := p.opts.MaxDescentDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxDescentDepth
+	}
+
+	if depth > maxDepth {
+		return &ElementDepthError{n.path, depth, maxDepth}
+	}
+
+	if err := p.canceled(); err != nil {
+		return err
+	}
+
+	if isScalar(n.data) || (p.opts.FirstOnly && len(*res) > 0) {
+		return nil
+	}
+
+	current, err := p.parseParams(n, g.next)
+
+	var unmarshalErr *UnmarshalError
+
+	switch {
+	case errors.As(err, &unmarshalErr):
+	case err != nil:
+		return err
+	default:
+		for _, set := range current {
+			if resolves(set, g.meta) {
+				*res = append(*res, set)
+
+				if p.opts.FirstOnly {
+					return nil
+				}
+			}
+		}
+	}
+
+	if isArray(n.data) {
+		sliceJSON, err := p.array(n)
+		if err != nil {
+			return nil // nolint:nilerr // malformed values don't match
+		}
+
+		for i, element := range sliceJSON {
+			if err := p.walk(n.child(element, indexSegment(i)), g, depth+1, res); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	object, err := p.object(n)
+	if err != nil {
+		return nil // nolint:nilerr // malformed values don't match
+	}
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := p.walk(n.child(object[key], key), g, depth+1, res); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolves reports whether set holds any param of meta.
+func resolves(set RawMessageSet, meta []MetaData) bool {
+	for _, m := range meta {
+		if _, ok := set[m.ParamID]; ok {
+			return true
+		}
+	}
+
+	return false
+}
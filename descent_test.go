@@ -0,0 +1,135 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestParseParamsRecursiveDescent(t *testing.T) {
+	meta := []jparser.MetaData{{Path: "**.statusString", ParamID: "status"}}
+
+	active := json.RawMessage(`"Действующее"`)
+	closed := json.RawMessage(
+		`"Индивидуальный предприниматель прекратил деятельность в связи с принятием им соответствующего решения"`)
+
+	testTable := []struct {
+		name        string
+		data        json.RawMessage
+		expectedRes []jparser.RawMessageSet
+	}{
+		{
+			name:        "Object",
+			data:        oneObjectInJSON,
+			expectedRes: []jparser.RawMessageSet{{"status": active}},
+		},
+		{
+			name:        "Array",
+			data:        multipleElementsInArrayJSON,
+			expectedRes: []jparser.RawMessageSet{{"status": active}, {"status": closed}, {"status": closed}},
+		},
+		{
+			name: "Varying depth",
+			data: json.RawMessage(`{"statusString": "a", "b": [{"c": {"statusString": "c"}}, {"statusString": "b"}]}`),
+			expectedRes: []jparser.RawMessageSet{
+				{"status": json.RawMessage(`"a"`)},
+				{"status": json.RawMessage(`"c"`)},
+				{"status": json.RawMessage(`"b"`)},
+			},
+		},
+		{
+			name:        "No match",
+			data:        json.RawMessage(`{"status": {}}`),
+			expectedRes: []jparser.RawMessageSet{{}},
+		},
+	}
+
+	for _, codec := range testCodecs {
+		for _, test := range testTable {
+			t.Run(codec.name+"/"+test.name, func(t *testing.T) {
+				result, err := jparser.ParseParamsWithOptions(test.data, meta, jparser.Options{Codec: codec.codec})
+				if err != nil {
+					t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+					return
+				}
+
+				if !reflect.DeepEqual(result, test.expectedRes) {
+					t.Errorf("ParseParamsWithOptions() got result = %v, expectedRes = %v", result, test.expectedRes)
+				}
+			})
+		}
+	}
+}
+
+func TestParseParamsRecursiveDescentDepth(t *testing.T) {
+	data := json.RawMessage(strings.Repeat(`{"a":`, 200) + `{"statusString": "deep"}` + strings.Repeat(`}`, 200))
+
+	_, err := jparser.ParseParams(data, []jparser.MetaData{{Path: "**.statusString", ParamID: "status"}})
+
+	var depthErr *jparser.ElementDepthError
+	if !errors.As(err, &depthErr) {
+		t.Fatalf("ParseParams() got error = \"%v\", expected *ElementDepthError", err)
+	}
+
+	result, err := jparser.ParseParamsWithOptions(data, []jparser.MetaData{{Path: "**.statusString", ParamID: "status"}},
+		jparser.Options{MaxDescentDepth: 300})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{{"status": json.RawMessage(`"deep"`)}}
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParamsWithOptions() got result = %v, expectedRes = %v", result, expectedRes)
+	}
+}
+
+func TestParseParamsFirstOnly(t *testing.T) {
+	testTable := []struct {
+		name        string
+		data        json.RawMessage
+		meta        []jparser.MetaData
+		expectedRes []jparser.RawMessageSet
+	}{
+		{
+			name:        "Recursive descent",
+			data:        multipleElementsInArrayJSON,
+			meta:        []jparser.MetaData{{Path: "**.statusString", ParamID: "status"}},
+			expectedRes: []jparser.RawMessageSet{{"status": json.RawMessage(`"Действующее"`)}},
+		},
+		{
+			name:        "Recursive descent at varying depth",
+			data:        json.RawMessage(`{"b": [{"c": {"statusString": "c"}}, {"statusString": "b"}], "statusString": "a"}`),
+			meta:        []jparser.MetaData{{Path: "**.statusString", ParamID: "status"}},
+			expectedRes: []jparser.RawMessageSet{{"status": json.RawMessage(`"a"`)}},
+		},
+		{
+			name: "Wildcard",
+			data: json.RawMessage(`{"contactPhones": {"77": {"count": 2}, "66": {"count": 5}}}`),
+			meta: []jparser.MetaData{
+				{Path: "contactPhones.*.count", ParamID: "count"},
+				{Path: "contactPhones.*.%", ParamID: "region"},
+			},
+			expectedRes: []jparser.RawMessageSet{{"count": json.RawMessage(`5`), "region": json.RawMessage(`"66"`)}},
+		},
+	}
+
+	for _, codec := range testCodecs {
+		for _, test := range testTable {
+			t.Run(codec.name+"/"+test.name, func(t *testing.T) {
+				result, err := jparser.ParseParamsWithOptions(test.data, test.meta,
+					jparser.Options{FirstOnly: true, Codec: codec.codec})
+				if err != nil {
+					t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+				}
+
+				if !reflect.DeepEqual(result, test.expectedRes) {
+					t.Errorf("ParseParamsWithOptions() got result = %s, expectedRes = %s", result, test.expectedRes)
+				}
+			})
+		}
+	}
+}
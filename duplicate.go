@@ -0,0 +1,228 @@
+package jparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DuplicatePolicy is the treatment of meta entries sharing a ParamID, see
+// Options.DuplicateParamID.
+type DuplicatePolicy int
+
+const (
+	// DuplicateLast binds the value merged last, in the order result sets
+	// are combined, see ParseParams.
+	DuplicateLast DuplicatePolicy = iota
+	// DuplicateFirst binds the value of the first entry in meta order whose
+	// path resolves.
+	DuplicateFirst
+	// DuplicateArray binds a JSON array of the values of the entries whose
+	// paths resolve, in meta order.
+	DuplicateArray
+	// DuplicateError fails NewParser with a *MetaError.
+	DuplicateError
+)
+
+// duplicateSeparator separates a ParamID from the occurrence number of a
+// duplicate entry in the internal ParamID it is parsed under.
+const duplicateSeparator = "\x00"
+
+// renameDuplicates gives every entry of meta repeating an earlier ParamID an
+// internal ParamID of its own, so that the values of all entries survive to
+// be merged by mergeDuplicates. Templated ParamIDs are left as is.
+func renameDuplicates(meta []MetaData, policy DuplicatePolicy) ([]MetaData, error) {
+	if policy == DuplicateLast {
+		return meta, nil
+	}
+
+	res := make([]MetaData, len(meta))
+	seen := make(map[string]int, len(meta))
+
+	for i, m := range meta {
+		if !isTemplate(m.ParamID) {
+			if n := seen[m.ParamID]; n > 0 {
+				if policy == DuplicateError {
+					return nil, &MetaError{m.ParamID, m.Path, "duplicate ParamID", nil}
+				}
+
+				m.ParamID += duplicateSeparator + strconv.Itoa(n)
+			}
+
+			seen[meta[i].ParamID]++
+		}
+
+		res[i] = m
+	}
+
+	return res, nil
+}
+
+// originalParamID strips the occurrence number of a duplicate entry from a
+// key of a result set, keeping any companion suffix.
+func originalParamID(key string) (original string, occurrence int) {
+	i := strings.Index(key, duplicateSeparator)
+	if i < 0 {
+		return key, 0
+	}
+
+	rest := key[i+len(duplicateSeparator):]
+
+	j := 0
+	for j < len(rest) && rest[j] >= '0' && rest[j] <= '9' {
+		j++
+	}
+
+	occurrence, _ = strconv.Atoi(rest[:j])
+
+	return key[:i] + rest[j:], occurrence
+}
+
+// mergeDuplicates merges the values bound under internal ParamIDs by
+// renameDuplicates back under their ParamID.
+func (p *Parser) mergeDuplicates(res []RawMessageSet) []RawMessageSet {
+	if p.opts.DuplicateParamID == DuplicateLast {
+		return res
+	}
+
+	for _, set := range res {
+		var occurrences map[string]map[int]json.RawMessage
+
+		for key, value := range set {
+			original, occurrence := originalParamID(key)
+			if original == key && !p.duplicated[key] {
+				continue
+			}
+
+			if occurrences == nil {
+				occurrences = make(map[string]map[int]json.RawMessage)
+			}
+
+			if occurrences[original] == nil {
+				occurrences[original] = make(map[int]json.RawMessage)
+			}
+
+			occurrences[original][occurrence] = value
+
+			delete(set, key)
+		}
+
+		for original, values := range occurrences {
+			// The first occurrence of a companion is bound under its key.
+			if value, ok := set[original]; ok {
+				values[0] = value
+			}
+
+			set[original] = p.mergeValues(values)
+		}
+	}
+
+	return res
+}
+
+// mergeValues merges the values of the occurrences of a ParamID.
+func (p *Parser) mergeValues(values map[int]json.RawMessage) json.RawMessage {
+	occurrences := make([]int, 0, len(values))
+	for occurrence := range values {
+		occurrences = append(occurrences, occurrence)
+	}
+
+	sort.Ints(occurrences)
+
+	if p.opts.DuplicateParamID == DuplicateFirst {
+		return values[occurrences[0]]
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteByte('[')
+
+	for i, occurrence := range occurrences {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		buf.Write(values[occurrence])
+	}
+
+	buf.WriteByte(']')
+
+	return buf.Bytes()
+}
+
+// restoreParamID replaces the internal ParamID of a duplicate entry in err
+// by its ParamID.
+func restoreParamID(err error) error {
+	var multiErr *MultiError
+	if errors.As(err, &multiErr) {
+		for _, err := range multiErr.Errors {
+			restoreParamID(err)
+		}
+
+		return err
+	}
+
+	var (
+		unmarshalErr *UnmarshalError
+		missingErr   *MissingError
+		containerErr *ContainerError
+		templateErr  *TemplateError
+		schemaErr    *SchemaError
+		metaErr      *MetaError
+		transformErr *TransformError
+	)
+
+	switch {
+	case errors.As(err, &unmarshalErr):
+		unmarshalErr.paramID, _ = originalParamID(unmarshalErr.paramID)
+	case errors.As(err, &missingErr):
+		missingErr.ParamID, _ = originalParamID(missingErr.ParamID)
+	case errors.As(err, &containerErr):
+		containerErr.ParamID, _ = originalParamID(containerErr.ParamID)
+	case errors.As(err, &templateErr):
+		templateErr.ParamID, _ = originalParamID(templateErr.ParamID)
+	case errors.As(err, &schemaErr):
+		schemaErr.ParamID, _ = originalParamID(schemaErr.ParamID)
+	case errors.As(err, &metaErr):
+		metaErr.ParamID, _ = originalParamID(metaErr.ParamID)
+	case errors.As(err, &transformErr):
+		transformErr.ParamID, _ = originalParamID(transformErr.ParamID)
+	}
+
+	return err
+}
+
+// duplicateKey returns the first key repeated by the members of the JSON
+// object data, which unmarshalling into a map would silently overwrite.
+func (p *parser) duplicateKey(data json.RawMessage) (string, bool) {
+	dec := newScanDecoder(p.codec(), data)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return "", false
+	}
+
+	seen := map[string]bool{}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", false
+		}
+
+		key, _ := tok.(string)
+		if seen[key] {
+			return key, true
+		}
+
+		seen[key] = true
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return "", false
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,46 @@
+package jparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// transcode converts data to UTF-8. A UTF-8 or UTF-16 byte order mark takes
+// precedence; otherwise enc is used, and data is returned as is if enc is nil.
+func transcode(data json.RawMessage, enc encoding.Encoding) (json.RawMessage, error) {
+	if enc == nil {
+		if !hasBOM(data) {
+			return data, nil
+		}
+
+		enc = encoding.Nop
+	}
+
+	res, _, err := transform.Bytes(unicode.BOMOverride(enc.NewDecoder()), data)
+	if err != nil {
+		return nil, fmt.Errorf("transcode input to UTF-8: %w", err)
+	}
+
+	return res, nil
+}
+
+// transcodeReader is transcode for a stream.
+func transcodeReader(r io.Reader, enc encoding.Encoding) io.Reader {
+	if enc == nil {
+		enc = encoding.Nop
+	}
+
+	return transform.NewReader(r, unicode.BOMOverride(enc.NewDecoder()))
+}
+
+func hasBOM(data []byte) bool {
+	return bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}) ||
+		bytes.HasPrefix(data, []byte{0xFE, 0xFF}) ||
+		bytes.HasPrefix(data, []byte{0xFF, 0xFE})
+}
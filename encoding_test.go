@@ -0,0 +1,80 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/egelis/jparser"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestParseParamsEncoding(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "inn", ParamID: "inn"},
+		{Path: "IP.status.statusString", ParamID: "status"},
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		{
+			"inn":    json.RawMessage(`"772473497153"`),
+			"status": json.RawMessage(`"Действующее"`),
+		},
+	}
+
+	utf16LE, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes(oneObjectInJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	utf16BE, err := unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewEncoder().Bytes(oneObjectInJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	windows1251, err := charmap.Windows1251.NewEncoder().Bytes(oneObjectInJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testTable := []struct {
+		name string
+		data json.RawMessage
+		opts jparser.Options
+	}{
+		{
+			name: "UTF-16LE with BOM",
+			data: utf16LE,
+		},
+		{
+			name: "UTF-16BE with BOM",
+			data: utf16BE,
+		},
+		{
+			name: "UTF-8 with BOM",
+			data: append([]byte{0xEF, 0xBB, 0xBF}, oneObjectInJSON...),
+		},
+		{
+			name: "Windows-1251",
+			data: windows1251,
+			opts: jparser.Options{Encoding: charmap.Windows1251},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := jparser.ParseParamsWithOptions(test.data, meta, test.opts)
+			if err != nil {
+				t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+				return
+			}
+
+			if !reflect.DeepEqual(result, expectedRes) {
+				got, _ := json.MarshalIndent(result, "", "  ")
+				expected, _ := json.MarshalIndent(expectedRes, "", "  ")
+				t.Errorf("ParseParamsWithOptions() got result = %s\nexpectedRes = %s", got, expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package jparser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// snippetLen is the maximum length of UnmarshalError.Snippet.
+const snippetLen = 64
+
+type UnmarshalError struct {
+	err     error
+	paramID string
+	// Path is the resolved location of the value that failed to decode,
+	// e.g. "[0].UL.branches", "" for the document itself.
+	Path string
+	// Snippet is the start of the value that failed to decode, compacted
+	// and truncated to 64 bytes.
+	Snippet string
+}
+
+// unmarshalError returns an *UnmarshalError for the value of n.
+func (p *parser) unmarshalError(err error, paramID string, n *node) *UnmarshalError {
+	return &UnmarshalError{err: err, paramID: paramID, Path: n.path, Snippet: snippet(p.codec(), n.data)}
+}
+
+func (e *UnmarshalError) Error() string {
+	msg := fmt.Sprintf("error: %s, param_id: %s", e.err, e.paramID)
+	if e.Path != "" || e.Snippet != "" {
+		msg += fmt.Sprintf(", path: %s, data: %s", e.Path, e.Snippet)
+	}
+
+	return msg
+}
+
+// ParamID returns the ID of the param whose path failed to unmarshal.
+func (e *UnmarshalError) ParamID() string {
+	return e.paramID
+}
+
+// Err returns the underlying decoding error.
+func (e *UnmarshalError) Err() error {
+	return e.err
+}
+
+func (e *UnmarshalError) Unwrap() error {
+	return e.err
+}
+
+// UnderpopulatedError reports a result set with fewer populated params than
+// Options.MinPopulatedParams.
+type UnderpopulatedError struct {
+	Index     int
+	Populated int
+	Min       int
+}
+
+func (e *UnderpopulatedError) Error() string {
+	return fmt.Sprintf("error: result set %d has %d populated params, expected at least %d",
+		e.Index, e.Populated, e.Min)
+}
+
+// ElementDepthError reports an array element nested deeper than Options.MaxElementDepth.
+type ElementDepthError struct {
+	Path     string
+	Depth    int
+	MaxDepth int
+}
+
+func (e *ElementDepthError) Error() string {
+	return fmt.Sprintf("error: element nesting depth %d exceeds %d, path: %s", e.Depth, e.MaxDepth, e.Path)
+}
+
+// MissingError reports a required param whose path doesn't resolve. Path is
+// the resolved location of the first missing node.
+type MissingError struct {
+	ParamID string
+	Path    string
+}
+
+func (e *MissingError) Error() string {
+	return fmt.Sprintf("error: path %s not found, param_id: %s", e.Path, e.ParamID)
+}
+
+// ContainerError reports a value of the wrong JSON type on the path of a
+// param in strict mode, e.g. an array where an object key is looked up.
+type ContainerError struct {
+	ParamID  string
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *ContainerError) Error() string {
+	return fmt.Sprintf("error: expected %s, got %s, path: %s, param_id: %s", e.Expected, e.Actual, e.Path, e.ParamID)
+}
+
+// TemplateError reports a templated ParamID that can't be expanded for a
+// value: a reference missing from the object holding it, or a result
+// colliding with a declared ParamID.
+type TemplateError struct {
+	ParamID string
+	Path    string
+	Reason  string
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("error: %s, path: %s, param_id: %s", e.Reason, e.Path, e.ParamID)
+}
+
+// MetaError reports a malformed meta path found by Compile. Path is the
+// prefix of the path up to the offending segment.
+type MetaError struct {
+	ParamID string
+	Path    string
+	Reason  string
+	err     error
+}
+
+func (e *MetaError) Error() string {
+	return fmt.Sprintf("error: %s, path: %s, param_id: %s", e.Reason, e.Path, e.ParamID)
+}
+
+// Unwrap returns the error behind Reason, e.g. ErrAliasCycle, if any.
+func (e *MetaError) Unwrap() error {
+	return e.err
+}
+
+// TransformError reports a MetaData.Transform failing on the value found at
+// Path.
+type TransformError struct {
+	ParamID string
+	Path    string
+	Err     error
+}
+
+func (e *TransformError) Error() string {
+	return fmt.Sprintf("error: transform: %v, path: %s, param_id: %s", e.Err, e.Path, e.ParamID)
+}
+
+func (e *TransformError) Unwrap() error {
+	return e.Err
+}
+
+// GzipError reports a corrupt gzip stream, see ParseParamsGzip.
+type GzipError struct {
+	Err error
+}
+
+func (e *GzipError) Error() string {
+	return fmt.Sprintf("error: gzip: %v", e.Err)
+}
+
+func (e *GzipError) Unwrap() error {
+	return e.Err
+}
+
+// DuplicateKeyError reports an object repeating a key, see
+// Options.DetectDuplicateKeys.
+type DuplicateKeyError struct {
+	Key string
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("duplicate key %q", e.Key)
+}
+
+// PartialError reports a malformed document parsed up to Offset with
+// Options.BestEffort. ParseParams returns it together with the result sets of
+// the valid prefix.
+type PartialError struct {
+	Offset int64
+	Err    error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("error: partial result, invalid JSON at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError lists the result sets lacking a required param with
+// Options.DeferRequired.
+type ValidationError struct {
+	Sets []IncompleteSet
+}
+
+// IncompleteSet is a result set lacking the required params ParamIDs, in
+// meta order. Index is its position among the sets returned.
+type IncompleteSet struct {
+	Index    int
+	ParamIDs []string
+}
+
+func (e *ValidationError) Error() string {
+	sets := make([]string, len(e.Sets))
+	for i, set := range e.Sets {
+		sets[i] = fmt.Sprintf("set %d: %s", set.Index, strings.Join(set.ParamIDs, ", "))
+	}
+
+	return "error: required params missing, " + strings.Join(sets, "; ")
+}
+
+// MultiError holds every error found with Options.CollectErrors, in the order
+// of the meta branches they were found in.
+type MultiError struct {
+	Errors []error
+}
+
+func (e *MultiError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the collected errors matches target.
+func (e *MultiError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first of the collected errors that matches target.
+func (e *MultiError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// snippet returns data compacted and truncated to snippetLen bytes, on a
+// UTF-8 boundary.
+func snippet(codec JSONCodec, data []byte) string {
+	var buf bytes.Buffer
+	if err := codec.Compact(&buf, data); err == nil {
+		data = buf.Bytes()
+	}
+
+	if len(data) <= snippetLen {
+		return string(data)
+	}
+
+	i := snippetLen
+	for i > 0 && !utf8.RuneStart(data[i]) {
+		i--
+	}
+
+	return string(data[:i]) + "..."
+}
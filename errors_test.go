@@ -0,0 +1,248 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestUnmarshalErrorAccessors(t *testing.T) {
+	_, err := jparser.ParseParams(oneElementInArrayJSON, []jparser.MetaData{
+		{Path: "[].UL.branches.wrong_path", ParamID: "wrong_path_param"},
+	})
+
+	var unmarshalErr *jparser.UnmarshalError
+	if !errors.As(err, &unmarshalErr) {
+		t.Fatalf("ParseParams() got error = \"%v\", expected *UnmarshalError", err)
+	}
+
+	payload, err := json.Marshal(map[string]string{
+		"param": unmarshalErr.ParamID(),
+		"cause": unmarshalErr.Err().Error(),
+	})
+	if err != nil {
+		t.Fatalf("json.Marshal() got error = \"%v\", expected nil", err)
+	}
+
+	var decoded struct {
+		Param string `json:"param"`
+		Cause string `json:"cause"`
+	}
+
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() got error = \"%v\", expected nil", err)
+	}
+
+	if decoded.Param != "wrong_path_param" || decoded.Cause == "" {
+		t.Errorf("custom error payload = %s, expected param \"wrong_path_param\" with a cause", payload)
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "inn.[]", ParamID: "inn"},
+		{Path: "ogrn", ParamID: "ogrn"},
+		{Path: "IP.fio.last", ParamID: "last_name"},
+	}
+
+	_, err := jparser.ParseParams(oneObjectInJSON, meta)
+
+	var unmarshalErr *jparser.UnmarshalError
+	if !errors.As(err, &unmarshalErr) || unmarshalErr.ParamID() != "inn" {
+		t.Fatalf("ParseParams() got error = \"%v\", expected *UnmarshalError for \"inn\"", err)
+	}
+
+	res, err := jparser.ParseParamsWithOptions(oneObjectInJSON, meta, jparser.Options{CollectErrors: true})
+
+	var multiErr *jparser.MultiError
+	if !errors.As(err, &multiErr) || res != nil {
+		t.Fatalf("ParseParamsWithOptions() got result = %v, error = \"%v\", expected *MultiError", res, err)
+	}
+
+	var paramIDs []string
+
+	for _, err := range multiErr.Errors {
+		if errors.As(err, &unmarshalErr) {
+			paramIDs = append(paramIDs, unmarshalErr.ParamID())
+		}
+	}
+
+	if len(multiErr.Errors) != 2 || len(paramIDs) != 2 || paramIDs[0] != "inn" || paramIDs[1] != "last_name" {
+		t.Errorf("ParseParamsWithOptions() got errors = %v, expected unmarshal errors for inn and last_name",
+			multiErr.Errors)
+	}
+
+	err = jparser.ValidateWithOptions(oneObjectInJSON, meta, jparser.Options{CollectErrors: true})
+	if !errors.As(err, &multiErr) {
+		t.Errorf("ValidateWithOptions() got error = \"%v\", expected *MultiError", err)
+	}
+}
+
+func TestMultiErrorMatches(t *testing.T) {
+	_, collected := jparser.ParseParamsWithOptions(oneObjectInJSON, []jparser.MetaData{
+		{Path: "inn.[]", ParamID: "inn"},
+		{Path: "IP.fio.last", ParamID: "last_name"},
+	}, jparser.Options{CollectErrors: true})
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "Collected", err: collected},
+		{name: "Wrapped", err: fmt.Errorf("parse: %w", collected)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var unmarshalErr *jparser.UnmarshalError
+			if !errors.As(test.err, &unmarshalErr) || unmarshalErr.ParamID() != "inn" {
+				t.Errorf("errors.As() got error = \"%v\", expected *UnmarshalError for \"inn\"", test.err)
+			}
+		})
+	}
+
+	multiErr := &jparser.MultiError{Errors: []error{fmt.Errorf("rows: %w", jparser.ErrTooManyRows)}}
+	if !errors.Is(multiErr, jparser.ErrTooManyRows) || errors.Is(multiErr, jparser.ErrMaxDepthExceeded) {
+		t.Errorf("errors.Is() got unexpected matches for \"%v\"", multiErr)
+	}
+}
+
+func TestUnmarshalErrorPath(t *testing.T) {
+	testTable := []struct {
+		name            string
+		meta            []jparser.MetaData
+		expectedPath    string
+		expectedSnippet string
+	}{
+		{
+			name:            "array addressed as object",
+			meta:            []jparser.MetaData{{Path: "[].UL.branches.wrong_path", ParamID: "wrong_path_param"}},
+			expectedPath:    "[0].UL.branches",
+			expectedSnippet: `[{"kpp":"771543001","parsedAddressRF":{`,
+		},
+		{
+			name:            "object addressed as array",
+			meta:            []jparser.MetaData{{Path: "[].UL.[].wrong_path", ParamID: "wrong_path"}},
+			expectedPath:    "[0].UL",
+			expectedSnippet: `{"kpp":"667101001","okpo":"00242766",`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := jparser.ParseParams(oneElementInArrayJSON, testCase.meta)
+
+			var unmarshalErr *jparser.UnmarshalError
+			if !errors.As(err, &unmarshalErr) {
+				t.Fatalf("ParseParams() got error = \"%v\", expected *UnmarshalError", err)
+			}
+
+			if unmarshalErr.Path != testCase.expectedPath ||
+				!strings.HasPrefix(unmarshalErr.Snippet, testCase.expectedSnippet) ||
+				!strings.HasSuffix(unmarshalErr.Snippet, "...") {
+				t.Errorf("UnmarshalError got path = %q, snippet = %q, expected %q, %q...",
+					unmarshalErr.Path, unmarshalErr.Snippet, testCase.expectedPath, testCase.expectedSnippet)
+			}
+
+			if !strings.Contains(err.Error(), "param_id: "+unmarshalErr.ParamID()+", path: "+testCase.expectedPath) {
+				t.Errorf("Error() = %q, expected the param_id followed by the path", err.Error())
+			}
+		})
+	}
+}
+
+func TestUnmarshalErrorUnwrap(t *testing.T) {
+	_, err := jparser.ParseParams(brokenJSON, []jparser.MetaData{{Path: "[].inn", ParamID: "inn"}})
+
+	var unmarshalErr *jparser.UnmarshalError
+	if !errors.As(err, &unmarshalErr) || unmarshalErr.ParamID() != "inn" {
+		t.Fatalf("ParseParams() got error = \"%v\", expected *UnmarshalError for \"inn\"", err)
+	}
+
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("ParseParams() got error = \"%v\", expected to wrap *json.SyntaxError", err)
+	}
+
+	if !errors.Is(err, unmarshalErr.Err()) {
+		t.Errorf("errors.Is() got false for the underlying error of \"%v\"", err)
+	}
+}
+
+func TestParseParamsBestEffort(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "[].inn", ParamID: "inn"},
+		{Path: "[].ogrn", ParamID: "ogrn"},
+		{Path: "[].UL.kpp", ParamID: "kpp"},
+	}
+
+	testCases := []struct {
+		name     string
+		data     json.RawMessage
+		expected []jparser.RawMessageSet
+	}{
+		{
+			name: "Member without a key",
+			data: brokenJSON,
+			expected: []jparser.RawMessageSet{
+				{"inn": json.RawMessage(`"7452160483"`), "ogrn": json.RawMessage(`"1227400033629"`)},
+			},
+		},
+		{
+			name: "Truncated in a string",
+			data: json.RawMessage(`[{"inn": "1", "UL": {"kpp": "2"}}, {"inn": "3", "ogrn": "4`),
+			expected: []jparser.RawMessageSet{
+				{"inn": json.RawMessage(`"1"`), "kpp": json.RawMessage(`"2"`)},
+				{"inn": json.RawMessage(`"3"`)},
+			},
+		},
+		{
+			name: "HTML characters",
+			data: json.RawMessage(`[{"inn": "1", "<a&b>": "x", "UL": {"kpp": "A&B <Co>"}}, {`),
+			expected: []jparser.RawMessageSet{
+				{"inn": json.RawMessage(`"1"`), "kpp": json.RawMessage(`"A&B <Co>"`)},
+				{},
+			},
+		},
+		{
+			name: "Truncated after a key",
+			data: json.RawMessage(`[{"inn": "1", "UL": {"kpp": `),
+			expected: []jparser.RawMessageSet{
+				{"inn": json.RawMessage(`"1"`)},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if result, err := jparser.ParseParams(testCase.data, meta); err == nil || result != nil {
+				t.Fatalf("ParseParams() got %s, error = \"%v\", expected nil and an error", result, err)
+			}
+
+			result, err := jparser.ParseParamsWithOptions(testCase.data, meta, jparser.Options{BestEffort: true})
+
+			var partialErr *jparser.PartialError
+			if !errors.As(err, &partialErr) {
+				t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected *PartialError", err)
+			}
+
+			if partialErr.Offset <= 0 || partialErr.Offset > int64(len(testCase.data)) {
+				t.Errorf("PartialError.Offset got %d, expected within the document", partialErr.Offset)
+			}
+
+			if !reflect.DeepEqual(result, testCase.expected) {
+				t.Errorf("ParseParamsWithOptions() got %s, expected %s", result, testCase.expected)
+			}
+		})
+	}
+
+	result, err := jparser.ParseParamsWithOptions(oneElementInArrayJSON, meta[:1], jparser.Options{BestEffort: true})
+	if err != nil || len(result) != 1 {
+		t.Errorf("ParseParamsWithOptions() got %s, error = \"%v\", expected one set and nil", result, err)
+	}
+}
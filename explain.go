@@ -0,0 +1,35 @@
+package jparser
+
+import "encoding/json"
+
+// ExplainPaths returns the resolved paths of the values meta binds in data,
+// e.g. "[0].UL.branches.[2].kpp", once each in the order they are reached:
+// meta order for the segments of a node, document order for array elements.
+// They tell which values the result sets of ParseParams combine. Values of
+// "[*]", aggregates and "&now", and defaults, have none.
+func ExplainPaths(data json.RawMessage, meta []MetaData) ([]string, error) {
+	p, err := NewParser(meta, Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	pp := p.newRun()
+	pp.discard = true
+	pp.explained = &[]string{}
+
+	if _, err := p.parse(pp, data); err != nil {
+		return nil, restoreParamID(err)
+	}
+
+	seen := make(map[string]bool, len(*pp.explained))
+	paths := []string{}
+
+	for _, path := range *pp.explained {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	return paths, nil
+}
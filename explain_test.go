@@ -0,0 +1,37 @@
+package jparser_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestExplainPaths(t *testing.T) {
+	paths, err := jparser.ExplainPaths(oneElementInArrayJSON, []jparser.MetaData{
+		{Path: "[].inn", ParamID: "inn"},
+		{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+		{Path: "[].UL.branches.#", ParamID: "branches"},
+		{Path: "[].UL.missing", ParamID: "missing"},
+	})
+	if err != nil {
+		t.Fatalf("ExplainPaths() got error = \"%v\", expected nil", err)
+	}
+
+	expected := []string{
+		"[0].inn",
+		"[0].UL.branches.[0].kpp",
+		"[0].UL.branches.[1].kpp",
+		"[0].UL.branches.[2].kpp",
+		"[0].UL.branches.[3].kpp",
+		"[0].UL.branches.[4].kpp",
+		"[0].UL.branches.#",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("ExplainPaths() got %q, expected %q", paths, expected)
+	}
+
+	if _, err := jparser.ExplainPaths(brokenJSON, []jparser.MetaData{{Path: "[].inn", ParamID: "inn"}}); err == nil {
+		t.Errorf("ExplainPaths() got error = nil for brokenJSON, expected error")
+	}
+}
@@ -0,0 +1,14 @@
+package jparser
+
+// NewGeneralPathParser is NewParser parsing plain levels like any other, to
+// check the fast path against it.
+func NewGeneralPathParser(meta []MetaData, opts Options) (*Parser, error) {
+	p, err := NewParser(meta, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	p.generalPath = true
+
+	return p, nil
+}
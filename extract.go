@@ -0,0 +1,109 @@
+package jparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// ExtractAll binds every scalar leaf of data under its dotted path, written
+// the way a MetaData path addressing it would be, e.g. "[].UL.branches.[].kpp".
+// Keys that would not parse back as themselves are quoted, e.g. `["a.b"]`.
+// Arrays fan out into separate result sets like "[]" does in ParseParams.
+func ExtractAll(data json.RawMessage) ([]RawMessageSet, error) {
+	return ExtractAllWithCodec(data, nil)
+}
+
+// ExtractAllWithCodec is ExtractAll decoding with codec, or encoding/json if
+// it is nil.
+func ExtractAllWithCodec(data json.RawMessage, codec JSONCodec) ([]RawMessageSet, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return []RawMessageSet{{}}, nil
+	}
+
+	p := &parser{opts: Options{Codec: codec}}
+
+	return p.extractLeaves(data, "")
+}
+
+func (p *parser) extractLeaves(data json.RawMessage, path string) ([]RawMessageSet, error) {
+	switch jsonType(data) {
+	case "object":
+		var object map[string]json.RawMessage
+		if err := p.codec().Unmarshal(data, &object); err != nil {
+			return nil, &UnmarshalError{err: err, paramID: path}
+		}
+
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		res := []RawMessageSet{{}}
+
+		for _, key := range keys {
+			currentRes, err := p.extractLeaves(object[key], joinPath(path, keySegment(key)))
+			if err != nil {
+				return nil, err
+			}
+
+			res = cartesianProduct(res, currentRes)
+		}
+
+		return res, nil
+	case "array":
+		var sliceJSON []json.RawMessage
+		if err := p.codec().Unmarshal(data, &sliceJSON); err != nil {
+			return nil, &UnmarshalError{err: err, paramID: path}
+		}
+
+		if len(sliceJSON) == 0 {
+			return []RawMessageSet{{}}, nil
+		}
+
+		var res []RawMessageSet
+
+		for _, JSON := range sliceJSON {
+			currentRes, err := p.extractLeaves(JSON, joinPath(path, "[]"))
+			if err != nil {
+				return nil, err
+			}
+
+			res = append(res, currentRes...)
+		}
+
+		return res, nil
+	default:
+		var v interface{}
+		if err := p.codec().Unmarshal(data, &v); err != nil {
+			return nil, &UnmarshalError{err: err, paramID: path}
+		}
+
+		return []RawMessageSet{{path: bytes.TrimSpace(data)}}, nil
+	}
+}
+
+// jsonType sniffs the type of a JSON value from its first significant byte.
+func jsonType(data json.RawMessage) string {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return ""
+	}
+
+	switch data[0] {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "boolean"
+	case 'n':
+		return "null"
+	default:
+		return "number"
+	}
+}
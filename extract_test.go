@@ -0,0 +1,94 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestExtractAll(t *testing.T) {
+	result, err := jparser.ExtractAll(oneObjectInJSON)
+	if err != nil {
+		t.Fatalf("ExtractAll() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		{
+			"inn":                                 json.RawMessage(`"772473497153"`),
+			"ogrn":                                json.RawMessage(`"318774600372150"`),
+			"focusHref":                           json.RawMessage(`"https://focus.kontur.ru/entity?query=318774600372150"`),
+			"IP.fio":                              json.RawMessage(`"Щербина Илья Владимирович"`),
+			"IP.okpo":                             json.RawMessage(`"0133585313"`),
+			"IP.okato":                            json.RawMessage(`"45296590000"`),
+			"IP.okfs":                             json.RawMessage(`"16"`),
+			"IP.okogu":                            json.RawMessage(`"4210015"`),
+			"IP.okopf":                            json.RawMessage(`"50102"`),
+			"IP.opf":                              json.RawMessage(`"Индивидуальные предприниматели"`),
+			"IP.oktmo":                            json.RawMessage(`"45923000000"`),
+			"IP.registrationDate":                 json.RawMessage(`"2018-07-11"`),
+			"IP.status.statusString":              json.RawMessage(`"Действующее"`),
+			"briefReport.summary.greenStatements": json.RawMessage(`true`),
+		},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		got, _ := json.MarshalIndent(result, "", "  ")
+		expected, _ := json.MarshalIndent(expectedRes, "", "  ")
+		t.Errorf("ExtractAll() got result = %s\nexpectedRes = %s", got, expected)
+	}
+}
+
+func TestExtractAllArrays(t *testing.T) {
+	result, err := jparser.ExtractAll(json.RawMessage(`{"a": [1, 2], "b": [{"c": true}, {"c": null}], "d": []}`))
+	if err != nil {
+		t.Fatalf("ExtractAll() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		{"a.[]": json.RawMessage(`1`), "b.[].c": json.RawMessage(`true`)},
+		{"a.[]": json.RawMessage(`1`), "b.[].c": json.RawMessage(`null`)},
+		{"a.[]": json.RawMessage(`2`), "b.[].c": json.RawMessage(`true`)},
+		{"a.[]": json.RawMessage(`2`), "b.[].c": json.RawMessage(`null`)},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		got, _ := json.MarshalIndent(result, "", "  ")
+		expected, _ := json.MarshalIndent(expectedRes, "", "  ")
+		t.Errorf("ExtractAll() got result = %s\nexpectedRes = %s", got, expected)
+	}
+
+	if _, err := jparser.ExtractAll(brokenJSON); err == nil {
+		t.Errorf("ExtractAll() got error = nil, expected error")
+	}
+}
+
+func TestExtractAllRoundTrip(t *testing.T) {
+	data := json.RawMessage(`{"a.b": 1, "": 2, "c": {"[0]": 3, "7": 4, "$x": 5, "d\\e": 6, "f~g": 7}, "h": 8}`)
+
+	extracted, err := jparser.ExtractAll(data)
+	if err != nil {
+		t.Fatalf("ExtractAll() got error = \"%v\", expected nil", err)
+	}
+
+	if len(extracted) != 1 {
+		t.Fatalf("ExtractAll() got %d sets, expected 1", len(extracted))
+	}
+
+	meta := make([]jparser.MetaData, 0, len(extracted[0]))
+	for path := range extracted[0] {
+		meta = append(meta, jparser.MetaData{Path: path, ParamID: path})
+	}
+
+	result, err := jparser.ParseParams(data, meta)
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	if !reflect.DeepEqual(result, extracted) {
+		got, _ := json.MarshalIndent(result, "", "  ")
+		expected, _ := json.MarshalIndent(extracted, "", "  ")
+		t.Errorf("ParseParams() got result = %s\nexpectedRes = %s", got, expected)
+	}
+}
@@ -0,0 +1,364 @@
+package jparser
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CompareOptions relaxes the string equality of "[?key=value]" filters.
+type CompareOptions struct {
+	// Trim ignores leading and trailing whitespace.
+	Trim bool
+	// FoldCase compares under Unicode case folding.
+	FoldCase bool
+	// CollapseSpace treats runs of whitespace as a single space, and implies
+	// Trim.
+	CollapseSpace bool
+}
+
+func (o CompareOptions) equal(a, b string) bool {
+	if o.Trim || o.CollapseSpace {
+		a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	}
+
+	if o.CollapseSpace {
+		a, b = strings.Join(strings.Fields(a), " "), strings.Join(strings.Fields(b), " ")
+	}
+
+	if o.FoldCase {
+		return strings.EqualFold(a, b)
+	}
+
+	return a == b
+}
+
+// filter is a "[?key=value]" segment, which iterates the elements of an
+// array like "[]", keeping those whose key equals value. A string value may
+// be quoted as a JSON string; other values are compared as compact JSON,
+// e.g. "[?count=77]".
+//
+// A "[?(...)]" segment keeps elements by their index instead, see
+// indexFilter.
+type filter struct {
+	key string
+	// value is written as in the segment, see (*parser).filterValue.
+	value string
+	index indexFilter
+}
+
+// parseFilter returns the filter of segment, or nil if it isn't one.
+func parseFilter(segment string) *filter {
+	if !strings.HasPrefix(segment, "[?") || !strings.HasSuffix(segment, "]") {
+		return nil
+	}
+
+	if strings.HasPrefix(segment, "[?(") && strings.HasSuffix(segment, ")]") {
+		index, ok := parseIndexFilter(segment[3 : len(segment)-2])
+		if !ok {
+			return nil
+		}
+
+		return &filter{index: index}
+	}
+
+	key, value, ok := strings.Cut(segment[2:len(segment)-1], "=")
+	if !ok || key == "" {
+		return nil
+	}
+
+	return &filter{key, value, nil}
+}
+
+// elementSet holds the elements iterated by a group.
+type elementSet struct {
+	values []json.RawMessage
+	// indices are the positions of values in the array, nil if all of its
+	// elements are kept.
+	indices []int
+	// keys are the object keys of values iterated by "*".
+	keys []string
+}
+
+// at returns the node of the i-th element below n, its index, which is the
+// position in the array or among the sorted keys for "*", and its key.
+func (e elementSet) at(n *node, i int) (*node, int, string) {
+	if e.keys != nil {
+		return n.child(e.values[i], e.keys[i]), i, e.keys[i]
+	}
+
+	index := i
+	if e.indices != nil {
+		index = e.indices[i]
+	}
+
+	return n.child(e.values[i], indexSegment(index)), index, ""
+}
+
+// head returns the first n elements of e.
+func (e elementSet) head(n int) elementSet {
+	if len(e.values) <= n {
+		return e
+	}
+
+	e.values = e.values[:n]
+
+	if e.indices != nil {
+		e.indices = e.indices[:n]
+	}
+
+	if e.keys != nil {
+		e.keys = e.keys[:n]
+	}
+
+	return e
+}
+
+// elements returns the elements of n iterated by g: the values of the object
+// n in key order for "*", otherwise the elements of the array n kept by the
+// filter or window of g.
+func (p *parser) elements(n *node, g *group) (elementSet, error) {
+	if p.iteratesObject(n, g) {
+		object, err := p.object(n)
+		if err != nil {
+			return elementSet{}, err
+		}
+
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		if p.opts.FirstOnly && g.segment == "*" && len(keys) > 1 {
+			keys = keys[:1]
+		}
+
+		values := make([]json.RawMessage, len(keys))
+		for i, key := range keys {
+			values[i] = object[key]
+		}
+
+		return elementSet{values: values, keys: keys}, nil
+	}
+
+	sliceJSON, err := p.iterated(n)
+	if err != nil || (g.filter == nil && g.window == nil) {
+		return elementSet{values: sliceJSON}, err
+	}
+
+	if g.window != nil {
+		start, end := g.window.bounds(len(sliceJSON))
+
+		indices := make([]int, 0, end-start)
+		for i := start; i < end; i++ {
+			indices = append(indices, i)
+		}
+
+		return elementSet{values: sliceJSON[start:end], indices: indices}, nil
+	}
+
+	kept := make([]json.RawMessage, 0, len(sliceJSON))
+	indices := make([]int, 0, len(sliceJSON))
+	value := p.filterValue(g.filter)
+
+	for i, element := range sliceJSON {
+		if p.keep(i, element, g.filter, value) {
+			kept = append(kept, element)
+			indices = append(indices, i)
+		}
+	}
+
+	return elementSet{values: kept, indices: indices}, nil
+}
+
+// iteratesObject reports whether g iterates the values of the object n, for
+// "*" or for "[]" with Options.ObjectAsArray.
+func (p *parser) iteratesObject(n *node, g *group) bool {
+	return g.segment == "*" || (g.segment == "[]" && p.opts.ObjectAsArray && isObject(n.data))
+}
+
+// iterated returns the elements of the array n, or n itself as the only one
+// with Options.ScalarAsArray.
+func (p *parser) iterated(n *node) ([]json.RawMessage, error) {
+	if p.opts.ScalarAsArray && !isArray(n.data) && jsonType(n.data) != "null" {
+		return []json.RawMessage{n.data}, nil
+	}
+
+	return p.array(n)
+}
+
+// keep reports whether f, of the given filterValue, keeps the array element
+// at index i.
+func (p *parser) keep(i int, element json.RawMessage, f *filter, value string) bool {
+	if f.index != nil {
+		return f.index.match(i)
+	}
+
+	return p.match(element, f.key, value)
+}
+
+// match reports whether the object element holds value under key. Anything
+// but an object doesn't match.
+func (p *parser) match(element json.RawMessage, key, expected string) bool {
+	var object RawMessageSet
+	if err := p.codec().Unmarshal(element, &object); err != nil {
+		return false
+	}
+
+	value, ok := object[key]
+	if !ok {
+		return false
+	}
+
+	var s string
+	if err := p.codec().Unmarshal(value, &s); err == nil {
+		return p.opts.FilterCompare.equal(s, expected)
+	}
+
+	canonical, err := canonicalJSON(value)
+
+	return err == nil && string(canonical) == expected
+}
+
+// filterValue returns the value of f unquoted if it is a JSON string.
+func (p *parser) filterValue(f *filter) string {
+	if f.index != nil {
+		return ""
+	}
+
+	var s string
+	if err := p.codec().Unmarshal([]byte(f.value), &s); err == nil {
+		return s
+	}
+
+	return f.value
+}
+
+// indexFilter is the expression of a "[?(...)]" segment, which keeps the
+// array elements whose index satisfies it, e.g. "[?(@==0||@==2)]" or
+// "[?(@%2==0&&@<10)]" for the even indices below 10. It is a disjunction
+// with "||" of conjunctions with "&&" of comparisons of "@", the index of
+// the element, or "@%N", its remainder modulo N > 0, with an integer by one
+// of "==", "!=", "<", "<=", ">" and ">=". Parentheses don't nest.
+type indexFilter [][]indexComparison
+
+type indexComparison struct {
+	modulus int
+	op      string
+	value   int
+}
+
+// indexOperators are the comparison operators of index filters, longest
+// first so that "<=" isn't taken for "<".
+var indexOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// parseIndexFilter parses the expression of an index filter, reporting
+// whether it is well-formed.
+func parseIndexFilter(expr string) (indexFilter, bool) {
+	var f indexFilter
+
+	for _, alternative := range strings.Split(expr, "||") {
+		var conjunction []indexComparison
+
+		for _, term := range strings.Split(alternative, "&&") {
+			c, ok := parseIndexComparison(strings.TrimSpace(term))
+			if !ok {
+				return nil, false
+			}
+
+			conjunction = append(conjunction, c)
+		}
+
+		f = append(f, conjunction)
+	}
+
+	return f, true
+}
+
+func parseIndexComparison(term string) (indexComparison, bool) {
+	var c indexComparison
+
+	if !strings.HasPrefix(term, "@") {
+		return c, false
+	}
+
+	rest := term[1:]
+
+	if strings.HasPrefix(rest, "%") {
+		rest = rest[1:]
+
+		end := strings.IndexAny(rest, "=!<>")
+		if end < 0 {
+			return c, false
+		}
+
+		modulus, err := strconv.Atoi(strings.TrimSpace(rest[:end]))
+		if err != nil || modulus <= 0 {
+			return c, false
+		}
+
+		c.modulus, rest = modulus, rest[end:]
+	}
+
+	rest = strings.TrimSpace(rest)
+
+	for _, op := range indexOperators {
+		if strings.HasPrefix(rest, op) {
+			n, err := strconv.Atoi(strings.TrimSpace(rest[len(op):]))
+			if err != nil {
+				return c, false
+			}
+
+			c.op, c.value = op, n
+
+			return c, true
+		}
+	}
+
+	return c, false
+}
+
+// match reports whether index i satisfies f.
+func (f indexFilter) match(i int) bool {
+	for _, conjunction := range f {
+		matched := true
+
+		for _, c := range conjunction {
+			if !c.match(i) {
+				matched = false
+
+				break
+			}
+		}
+
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c indexComparison) match(i int) bool {
+	if c.modulus > 0 {
+		i %= c.modulus
+	}
+
+	switch c.op {
+	case "==":
+		return i == c.value
+	case "!=":
+		return i != c.value
+	case "<":
+		return i < c.value
+	case "<=":
+		return i <= c.value
+	case ">":
+		return i > c.value
+	default:
+		return i >= c.value
+	}
+}
@@ -0,0 +1,144 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+var citiesJSON = json.RawMessage(`[
+	{"inn": "1", "city": " Екатеринбург ", "branches": 2},
+	{"inn": "2", "city": "екатеринбург", "branches": 77},
+	{"inn": "3", "city": "Нижний  Тагил"},
+	{"inn": "4", "city": "Москва", "branches": 77}
+]`)
+
+func TestParseParamsFilter(t *testing.T) {
+	testTable := []struct {
+		name        string
+		meta        []jparser.MetaData
+		compare     jparser.CompareOptions
+		expectedRes []jparser.RawMessageSet
+	}{
+		{
+			name:        "Exact by default",
+			meta:        []jparser.MetaData{{Path: "[?city=Екатеринбург].inn", ParamID: "inn"}},
+			expectedRes: []jparser.RawMessageSet{{}},
+		},
+		{
+			name:    "Trim",
+			meta:    []jparser.MetaData{{Path: "[?city=Екатеринбург].inn", ParamID: "inn"}},
+			compare: jparser.CompareOptions{Trim: true},
+			expectedRes: []jparser.RawMessageSet{
+				{"inn": json.RawMessage(`"1"`)},
+			},
+		},
+		{
+			name:    "Trim and fold case",
+			meta:    []jparser.MetaData{{Path: "[?city=Екатеринбург].inn", ParamID: "inn"}},
+			compare: jparser.CompareOptions{Trim: true, FoldCase: true},
+			expectedRes: []jparser.RawMessageSet{
+				{"inn": json.RawMessage(`"1"`)},
+				{"inn": json.RawMessage(`"2"`)},
+			},
+		},
+		{
+			name:    "Collapse whitespace",
+			meta:    []jparser.MetaData{{Path: `[?city="Нижний Тагил"].inn`, ParamID: "inn"}},
+			compare: jparser.CompareOptions{CollapseSpace: true},
+			expectedRes: []jparser.RawMessageSet{
+				{"inn": json.RawMessage(`"3"`)},
+			},
+		},
+		{
+			name: "Number with original index and count of matches",
+			meta: []jparser.MetaData{
+				{Path: "[?branches=77].@", ParamID: "index"},
+				{Path: "[?branches=77].#", ParamID: "count"},
+			},
+			expectedRes: []jparser.RawMessageSet{
+				{"index": json.RawMessage(`1`), "count": json.RawMessage(`2`)},
+				{"index": json.RawMessage(`3`), "count": json.RawMessage(`2`)},
+			},
+		},
+	}
+
+	for _, codec := range testCodecs {
+		for _, test := range testTable {
+			t.Run(codec.name+"/"+test.name, func(t *testing.T) {
+				result, err := jparser.ParseParamsWithOptions(citiesJSON, test.meta,
+					jparser.Options{FilterCompare: test.compare, Codec: codec.codec})
+				if err != nil {
+					t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+					return
+				}
+
+				if !reflect.DeepEqual(result, test.expectedRes) {
+					t.Errorf("ParseParamsWithOptions() got result = %v, expectedRes = %v", result, test.expectedRes)
+				}
+			})
+		}
+	}
+}
+
+func TestParseParamsIndexFilter(t *testing.T) {
+	testTable := []struct {
+		name     string
+		path     string
+		expected []string
+	}{
+		{
+			name:     "Indices 0 and 2",
+			path:     "[].UL.branches.[?(@==0||@==2)].kpp",
+			expected: []string{"771543001", "780243001"},
+		},
+		{
+			name:     "Even indices",
+			path:     "[].UL.branches.[?(@%2==0)].kpp",
+			expected: []string{"771543001", "780243001", "745343002"},
+		},
+		{
+			name:     "Range",
+			path:     "[].UL.branches.[?( @ >= 1 && @ < 4 && @ != 2 )].kpp",
+			expected: []string{"771543002", "590443001"},
+		},
+		{name: "Past the end", path: "[].UL.branches.[?(@>10)].kpp"},
+	}
+
+	for _, codec := range testCodecs {
+		for _, test := range testTable {
+			t.Run(codec.name+"/"+test.name, func(t *testing.T) {
+				result, err := jparser.ParseParamsWithOptions(oneElementInArrayJSON, []jparser.MetaData{
+					{Path: test.path, ParamID: "kpp"},
+					{Path: "[].UL.kpp", ParamID: "legal_kpp"},
+				}, jparser.Options{Codec: codec.codec})
+				if err != nil {
+					t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+				}
+
+				expected := make([]jparser.RawMessageSet, 0, len(test.expected))
+				for _, kpp := range test.expected {
+					expected = append(expected, jparser.RawMessageSet{
+						"kpp": json.RawMessage(`"` + kpp + `"`), "legal_kpp": json.RawMessage(`"667101001"`),
+					})
+				}
+
+				if len(expected) == 0 {
+					expected = []jparser.RawMessageSet{{"legal_kpp": json.RawMessage(`"667101001"`)}}
+				}
+
+				if !reflect.DeepEqual(result, expected) {
+					t.Errorf("ParseParamsWithOptions() got %s, expected %s", result, expected)
+				}
+			})
+		}
+	}
+
+	for _, path := range []string{"[?(@=0)]", "[?(@==)]", "[?(#==0)]", "[?(@%0==0)]", "[?(@==0||)]"} {
+		if err := jparser.ValidateMeta([]jparser.MetaData{{Path: path + ".kpp", ParamID: "kpp"}}); err == nil {
+			t.Errorf("ValidateMeta() got error = nil for %s, expected *MetaError", path)
+		}
+	}
+}
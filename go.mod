@@ -1,3 +1,5 @@
 module github.com/egelis/jparser
 
 go 1.19
+
+require golang.org/x/text v0.14.0
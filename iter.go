@@ -0,0 +1,94 @@
+package jparser
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// RowIter yields the result sets of a parse one at a time, see
+// (*Plan).Iterator.
+type RowIter struct {
+	next func() ([]RawMessageSet, bool, error)
+	rows []RawMessageSet
+	row  RawMessageSet
+	err  error
+}
+
+// Iterator returns an iterator over the result sets ParseParams returns for
+// data, in the same order. Under the conditions of (*Parser).ParseStream,
+// the sets of each root array element are built as the iterator reaches it,
+// so that only the decoded document is held in memory, never all the rows.
+// Otherwise they are built on the first call to Next.
+func (p *Plan) Iterator(data json.RawMessage) (*RowIter, error) {
+	data, err := p.prepare(data)
+	if err != nil {
+		return nil, restoreParamID(err)
+	}
+
+	pp := p.newRun()
+	array := isArray(data)
+
+	if g := p.streamGroup(p.level(array)); g != nil && array {
+		if dec, ok := pp.codec().NewDecoder(bytes.NewReader(data)).(tokenDecoder); ok {
+			s, err := p.newElementStream(pp, dec, g)
+			if err != nil {
+				return nil, restoreParamID(err)
+			}
+
+			return &RowIter{next: s.next}, nil
+		}
+	}
+
+	done := false
+	next := func() ([]RawMessageSet, bool, error) {
+		if done {
+			return nil, false, nil
+		}
+
+		done = true
+
+		res, err := pp.parseParams(&node{data: data}, p.level(array))
+		if err != nil {
+			return nil, false, err
+		}
+
+		res, err = p.finish(pp, res)
+
+		return res, err == nil, err
+	}
+
+	return &RowIter{next: next}, nil
+}
+
+// Next advances the iterator to the next result set, reporting false once
+// the sets are exhausted or parsing failed, see Err.
+func (it *RowIter) Next() bool {
+	for len(it.rows) == 0 {
+		if it.next == nil {
+			return false
+		}
+
+		rows, ok, err := it.next()
+		if err != nil || !ok {
+			it.next, it.err = nil, restoreParamID(err)
+
+			return false
+		}
+
+		it.rows = rows
+	}
+
+	it.row, it.rows = it.rows[0], it.rows[1:]
+
+	return true
+}
+
+// Row returns the current result set.
+func (it *RowIter) Row() RawMessageSet {
+	return it.row
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *RowIter) Err() error {
+	return it.err
+}
@@ -0,0 +1,124 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestPlanIterator(t *testing.T) {
+	testTable := []struct {
+		name string
+		data json.RawMessage
+		meta []jparser.MetaData
+	}{
+		{
+			name: "Streamed array",
+			data: syntheticArray(100),
+			meta: streamMeta,
+		},
+		{
+			name: "Empty array",
+			data: json.RawMessage(`[]`),
+			meta: streamMeta,
+		},
+		{
+			name: "Whole document",
+			data: multipleElementsInArrayJSON,
+			meta: append([]jparser.MetaData{{Path: "[].#", ParamID: "count"}}, sharedPrefixMeta...),
+		},
+		{
+			name: "Climbing to the root array",
+			data: syntheticArray(10),
+			meta: []jparser.MetaData{{Path: "[].inn", ParamID: "inn"}, {Path: "[].^", ParamID: "root"}},
+		},
+		{
+			name: "Object",
+			data: oneObjectInJSON,
+			meta: []jparser.MetaData{{Path: "inn", ParamID: "inn"}, {Path: "IP.fio", ParamID: "fio"}},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			expectedRes, err := jparser.ParseParams(testCase.data, testCase.meta)
+			if err != nil {
+				t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+			}
+
+			plan, err := jparser.Compile(testCase.meta)
+			if err != nil {
+				t.Fatalf("Compile() got error = \"%v\", expected nil", err)
+			}
+
+			it, err := plan.Iterator(testCase.data)
+			if err != nil {
+				t.Fatalf("Iterator() got error = \"%v\", expected nil", err)
+			}
+
+			var result []jparser.RawMessageSet
+			for it.Next() {
+				result = append(result, it.Row())
+			}
+
+			if err := it.Err(); err != nil {
+				t.Fatalf("Err() got error = \"%v\", expected nil", err)
+			}
+
+			if !reflect.DeepEqual(result, expectedRes) {
+				t.Errorf("Iterator() got rows = %v, expected %v", result, expectedRes)
+			}
+		})
+	}
+}
+
+func TestPlanIteratorError(t *testing.T) {
+	plan, err := jparser.Compile(streamMeta)
+	if err != nil {
+		t.Fatalf("Compile() got error = \"%v\", expected nil", err)
+	}
+
+	it, err := plan.Iterator(json.RawMessage(`[{"inn": "1"}, 2]`))
+	if err != nil {
+		t.Fatalf("Iterator() got error = \"%v\", expected nil", err)
+	}
+
+	rows := 0
+	for it.Next() {
+		rows++
+	}
+
+	var unmarshalErr *jparser.UnmarshalError
+	if rows != 1 || !errors.As(it.Err(), &unmarshalErr) {
+		t.Errorf("Iterator() got %d rows and error = \"%v\", expected 1 row and *UnmarshalError", rows, it.Err())
+	}
+
+	if it.Next() {
+		t.Errorf("Next() got true after an error, expected false")
+	}
+}
+
+func TestPlanIteratorMaxRows(t *testing.T) {
+	plan, err := jparser.CompileWithOptions([]jparser.MetaData{{Path: "[].inn", ParamID: "inn"}},
+		jparser.Options{MaxRows: 1})
+	if err != nil {
+		t.Fatalf("CompileWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	it, err := plan.Iterator(json.RawMessage(`[{"inn": "1"}, {"inn": "2"}, {"inn": "3"}]`))
+	if err != nil {
+		t.Fatalf("Iterator() got error = \"%v\", expected nil", err)
+	}
+
+	rows := 0
+	for it.Next() {
+		rows++
+	}
+
+	if rows != 1 || !errors.Is(it.Err(), jparser.ErrTooManyRows) {
+		t.Errorf("Iterator() got %d rows and error = \"%v\", expected 1 row and ErrTooManyRows", rows, it.Err())
+	}
+}
@@ -0,0 +1,86 @@
+package jparser
+
+import "encoding/json"
+
+// stripJSONC returns a copy of data with the comments and trailing commas of
+// JSONC replaced by spaces, see Options.JSONC. Newlines are kept, so that the
+// result is as long as data and offsets into it are offsets into data.
+// Unterminated comments run to the end of data.
+func stripJSONC(data json.RawMessage) json.RawMessage {
+	out := make(json.RawMessage, len(data))
+	copy(out, data)
+
+	blank := func(from, to int) {
+		for i := from; i < to; i++ {
+			if out[i] != '\n' {
+				out[i] = ' '
+			}
+		}
+	}
+
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '"':
+			i = stringEnd(out, i)
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			end := i
+			for end < len(out) && out[end] != '\n' {
+				end++
+			}
+
+			blank(i, end)
+			i = end
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := i + 2
+			for end+1 < len(out) && !(out[end] == '*' && out[end+1] == '/') {
+				end++
+			}
+
+			if end += 2; end > len(out) {
+				end = len(out)
+			}
+
+			blank(i, end)
+			i = end - 1
+		}
+	}
+
+	// Comments are gone, so a comma followed by whitespace only and a
+	// closing bracket is trailing.
+	for i := 0; i < len(out); i++ {
+		switch out[i] {
+		case '"':
+			i = stringEnd(out, i)
+		case ',':
+			next := i + 1
+			for next < len(out) && isSpace(out[next]) {
+				next++
+			}
+
+			if next < len(out) && (out[next] == '}' || out[next] == ']') {
+				out[i] = ' '
+			}
+		}
+	}
+
+	return out
+}
+
+// stringEnd returns the index of the quote closing the JSON string opening at
+// data[start], or the last index of data if it is unterminated.
+func stringEnd(data []byte, start int) int {
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+
+	return len(data) - 1
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,110 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestParseParamsJSONC(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "[].inn", ParamID: "inn"},
+		{Path: "[].url", ParamID: "url"},
+	}
+
+	tests := []struct {
+		name string
+		data json.RawMessage
+	}{
+		{
+			name: "line comments",
+			data: json.RawMessage(`// companies
+[
+    {
+        "inn": "6663003127", // legal entity
+        "url": "https://focus.kontur.ru"
+    }
+]`),
+		},
+		{
+			name: "block comments",
+			data: json.RawMessage(`/* companies */ [{"inn": /* legal
+entity */ "6663003127", "url": "https://focus.kontur.ru"}] /* end */`),
+		},
+		{
+			name: "trailing commas",
+			data: json.RawMessage(`[{"inn": "6663003127", "url": "https://focus.kontur.ru",},]`),
+		},
+		{
+			name: "trailing comma before a comment",
+			data: json.RawMessage(`[{"inn": "6663003127", "url": "https://focus.kontur.ru", // last
+}]`),
+		},
+	}
+
+	expected := []jparser.RawMessageSet{{
+		"inn": json.RawMessage(`"6663003127"`),
+		"url": json.RawMessage(`"https://focus.kontur.ru"`),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := jparser.ParseParams(test.data, meta); err == nil {
+				t.Fatalf("ParseParams() got error = nil, expected error")
+			}
+
+			result, err := jparser.ParseParamsWithOptions(test.data, meta, jparser.Options{JSONC: true})
+			if err != nil {
+				t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+			}
+
+			if !reflect.DeepEqual(result, expected) {
+				t.Errorf("ParseParamsWithOptions() got %s, expected %s", result, expected)
+			}
+
+			p, err := jparser.NewParser(meta, jparser.Options{JSONC: true})
+			if err != nil {
+				t.Fatalf("NewParser() got error = \"%v\", expected nil", err)
+			}
+
+			var streamed []jparser.RawMessageSet
+			if err := p.ParseStream(strings.NewReader(string(test.data)), func(set jparser.RawMessageSet) error {
+				streamed = append(streamed, set)
+
+				return nil
+			}); err != nil {
+				t.Fatalf("ParseStream() got error = \"%v\", expected nil", err)
+			}
+
+			if !reflect.DeepEqual(streamed, expected) {
+				t.Errorf("ParseStream() got %s, expected %s", streamed, expected)
+			}
+		})
+	}
+}
+
+func TestParseParamsJSONCKeepsStrings(t *testing.T) {
+	data := json.RawMessage(`{"note": "a // b /* c */ d,]", "quoted": "\" // e"}`)
+	meta := []jparser.MetaData{{Path: "note", ParamID: "note"}, {Path: "quoted", ParamID: "quoted"}}
+
+	result, err := jparser.ParseParamsWithOptions(data, meta, jparser.Options{JSONC: true})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	expected := []jparser.RawMessageSet{{
+		"note":   json.RawMessage(`"a // b /* c */ d,]"`),
+		"quoted": json.RawMessage(`"\" // e"`),
+	}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("ParseParamsWithOptions() got %s, expected %s", result, expected)
+	}
+
+	// brokenJSON has a trailing comma, but also a member without a key.
+	if _, err := jparser.ParseParamsWithOptions(brokenJSON, meta, jparser.Options{JSONC: true}); err == nil {
+		t.Errorf("ParseParamsWithOptions() got error = nil for brokenJSON, expected error")
+	}
+}
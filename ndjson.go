@@ -0,0 +1,87 @@
+package jparser
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// LineError reports a malformed NDJSON line. Line is 1-based.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("error: line %d: %s", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// ParseNDJSONContext parses every line of the newline-delimited JSON stream r
+// with meta and calls fn with each result set. Lines are read one at a time,
+// blank lines are ignored. It stops with ctx.Err() once ctx is done, checked
+// before each line, and with the first error returned by fn.
+func ParseNDJSONContext(ctx context.Context, r io.Reader, meta []MetaData, fn func(RawMessageSet) error) error {
+	return ParseNDJSONContextWithOptions(ctx, r, meta, Options{}, fn)
+}
+
+func ParseNDJSONContextWithOptions(
+	ctx context.Context, r io.Reader, meta []MetaData, opts Options, fn func(RawMessageSet) error,
+) error {
+	p, err := NewParser(meta, opts)
+	if err != nil {
+		return err
+	}
+
+	return p.ParseNDJSON(ctx, r, fn)
+}
+
+// ParseNDJSON is ParseNDJSONContext with the meta and options of p. Lines
+// failing to parse are returned as a *LineError, or skipped with
+// Options.SkipMalformedLines if they aren't valid JSON.
+func (p *Parser) ParseNDJSON(ctx context.Context, r io.Reader, fn func(RawMessageSet) error) error {
+	br := bufio.NewReader(r)
+
+	for line := 1; ; line++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		data, err := br.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		if len(bytes.TrimSpace(data)) > 0 {
+			res, parseErr := p.ParseParams(data)
+
+			switch {
+			case parseErr == nil:
+				for _, set := range res {
+					if err := fn(set); err != nil {
+						return err
+					}
+				}
+			case !p.opts.SkipMalformedLines || !isSyntaxError(parseErr):
+				return &LineError{line, parseErr}
+			}
+		}
+
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+// isSyntaxError reports whether err comes from malformed JSON rather than
+// from the meta, like a missing required param.
+func isSyntaxError(err error) bool {
+	return errors.As(err, new(*json.SyntaxError)) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,119 @@
+package jparser_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+var ndjsonMeta = []jparser.MetaData{{Path: "inn", ParamID: "inn"}}
+
+const ndjsonInput = `{"inn": "6663003127"}
+
+{"inn": "7736207543"
+{"inn": "772473497153"}
+`
+
+func TestParseNDJSONContext(t *testing.T) {
+	var result []jparser.RawMessageSet
+
+	err := jparser.ParseNDJSONContext(context.Background(), strings.NewReader(ndjsonInput), ndjsonMeta,
+		func(set jparser.RawMessageSet) error {
+			result = append(result, set)
+
+			return nil
+		})
+
+	var lineErr *jparser.LineError
+	if !errors.As(err, &lineErr) {
+		t.Fatalf("ParseNDJSONContext() got error = \"%v\", expected *LineError", err)
+	}
+
+	if lineErr.Line != 3 {
+		t.Errorf("LineError.Line = %d, expected 3", lineErr.Line)
+	}
+
+	expected := []jparser.RawMessageSet{{"inn": json.RawMessage(`"6663003127"`)}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("ParseNDJSONContext() got result = %v, expected %v", result, expected)
+	}
+}
+
+func TestParseNDJSONContextSkipMalformedLines(t *testing.T) {
+	var result []jparser.RawMessageSet
+
+	err := jparser.ParseNDJSONContextWithOptions(context.Background(), strings.NewReader(ndjsonInput), ndjsonMeta,
+		jparser.Options{SkipMalformedLines: true},
+		func(set jparser.RawMessageSet) error {
+			result = append(result, set)
+
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("ParseNDJSONContextWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	expected := []jparser.RawMessageSet{
+		{"inn": json.RawMessage(`"6663003127"`)},
+		{"inn": json.RawMessage(`"772473497153"`)},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("ParseNDJSONContextWithOptions() got result = %v, expected %v", result, expected)
+	}
+}
+
+func TestParseNDJSONContextSkipMalformedLinesOnly(t *testing.T) {
+	testTable := []struct {
+		name string
+		meta []jparser.MetaData
+	}{
+		{name: "Required", meta: []jparser.MetaData{{Path: "ogrn", ParamID: "ogrn", Required: true}}},
+		{
+			name: "Schema",
+			meta: []jparser.MetaData{{Path: "inn", ParamID: "inn", Schema: json.RawMessage(`{"type": "number"}`)}},
+		},
+		{name: "Type", meta: []jparser.MetaData{{Path: "inn.[]", ParamID: "inn"}}},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			err := jparser.ParseNDJSONContextWithOptions(context.Background(), strings.NewReader(ndjsonInput), test.meta,
+				jparser.Options{SkipMalformedLines: true},
+				func(jparser.RawMessageSet) error { return nil })
+
+			var lineErr *jparser.LineError
+			if !errors.As(err, &lineErr) || lineErr.Line != 1 {
+				t.Errorf("ParseNDJSONContextWithOptions() got error = \"%v\", expected *LineError for line 1", err)
+			}
+		})
+	}
+}
+
+func TestParseNDJSONContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	input := strings.Repeat(`{"inn": "6663003127"}`+"\n", 100)
+	calls := 0
+
+	err := jparser.ParseNDJSONContext(ctx, strings.NewReader(input), ndjsonMeta, func(jparser.RawMessageSet) error {
+		calls++
+		if calls == 10 {
+			cancel()
+		}
+
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ParseNDJSONContext() got error = \"%v\", expected context.Canceled", err)
+	}
+
+	if calls != 10 {
+		t.Errorf("ParseNDJSONContext() called fn %d times, expected 10", calls)
+	}
+}
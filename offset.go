@@ -0,0 +1,71 @@
+package jparser
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// offset returns the start of n in the document, or false if n isn't part of
+// it, like the values of tokens.
+func (n *node) offset(codec JSONCodec) (int, bool) {
+	if n.parent == nil {
+		return 0, n.data != nil
+	}
+
+	parentStart, ok := n.parent.offset(codec)
+	if !ok {
+		return 0, false
+	}
+
+	start, ok := n.parent.memberOffsets(codec)[n.segment]
+	if !ok || !bytes.HasPrefix(n.parent.data[start:], n.data) {
+		return 0, false
+	}
+
+	return parentStart + start, true
+}
+
+// memberOffsets returns the start of the values of the object or array n in
+// n.data by key or "[N]" index, scanned with codec.
+func (n *node) memberOffsets(codec JSONCodec) map[string]int {
+	n.offsetsOnce.Do(func() {
+		n.offsets = scanOffsets(codec, n.data)
+	})
+
+	return n.offsets
+}
+
+// scanOffsets returns the start of the values of the object or array data by
+// key, the last one for a repeated key, or "[N]" index.
+func scanOffsets(codec JSONCodec, data json.RawMessage) map[string]int {
+	dec := newScanDecoder(codec, data)
+
+	delim, err := dec.Token()
+	if err != nil || (delim != json.Delim('{') && delim != json.Delim('[')) {
+		return nil
+	}
+
+	offsets := make(map[string]int)
+
+	for i := 0; dec.More(); i++ {
+		segment := indexSegment(i)
+
+		if delim == json.Delim('{') {
+			key, err := dec.Token()
+			if err != nil {
+				return offsets
+			}
+
+			segment, _ = key.(string)
+		}
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return offsets
+		}
+
+		offsets[segment] = int(dec.InputOffset()) - len(value)
+	}
+
+	return offsets
+}
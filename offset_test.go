@@ -0,0 +1,52 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestParseParamsOffsets(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "[].inn", ParamID: "inn"},
+		{Path: "[].UL.legalName", ParamID: "legal_name"},
+		{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+		{Path: "[].UL.branches.[?parsedAddressRF.regionCode=77].parsedAddressRF.zipCode", ParamID: "moscow_zip"},
+		{Path: "[].contactPhones.*", ParamID: "phones"},
+		{Path: "[].**.statusString", ParamID: "status"},
+		{Path: "[].UL.branches.[].#", ParamID: "branches"},
+	}
+
+	result, err := jparser.ParseParamsWithOptions(oneElementInArrayJSON, meta, jparser.Options{OffsetSuffix: "_offset"})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	for i, set := range result {
+		for _, m := range meta {
+			value, ok := set[m.ParamID]
+			if !ok {
+				continue
+			}
+
+			rawOffset, ok := set[m.ParamID+"_offset"]
+			if m.ParamID == "branches" {
+				if ok {
+					t.Errorf("set %d got %s_offset = %s, expected none for a token", i, m.ParamID, rawOffset)
+				}
+
+				continue
+			}
+
+			var offset [2]int
+			if err := json.Unmarshal(rawOffset, &offset); err != nil {
+				t.Fatalf("set %d got %s_offset = %s, expected [start,end]", i, m.ParamID, rawOffset)
+			}
+
+			if got := string(oneElementInArrayJSON[offset[0]:offset[1]]); got != string(value) {
+				t.Errorf("set %d got data[%d:%d] = %s, expected %s", i, offset[0], offset[1], got, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package jparser
+
+import (
+	"strings"
+)
+
+// literalKey marks a path segment to be looked up as an object key even
+// though it reads as an operator. It differs from duplicateSeparator, so
+// that a marked segment never reads as a renamed duplicate.
+const literalKey = "\x01"
+
+// applyOperatorPrefix rewrites the paths of meta written with
+// Options.OperatorPrefix to the bare operator syntax, marking unprefixed
+// segments that would read as operators with literalKey.
+func applyOperatorPrefix(meta []MetaData, prefix string) []MetaData {
+	if prefix == "" {
+		return meta
+	}
+
+	res := make([]MetaData, len(meta))
+
+	for i, m := range meta {
+		res[i] = m
+		res[i].Path = prefixedPath(m.Path, prefix)
+	}
+
+	return res
+}
+
+func prefixedAliases(aliases map[string]string, prefix string) map[string]string {
+	if prefix == "" || len(aliases) == 0 {
+		return aliases
+	}
+
+	res := make(map[string]string, len(aliases))
+	for name, path := range aliases {
+		res[name] = prefixedPath(path, prefix)
+	}
+
+	return res
+}
+
+func prefixedPath(path, prefix string) string {
+	var segments []string
+
+	for rest := path; rest != ""; {
+		var segment string
+
+		if strings.HasPrefix(rest, prefix) {
+			segment, rest = splitPath(rest[len(prefix):])
+			segments = append(segments, segment)
+
+			continue
+		}
+
+		segment, rest = splitPath(rest)
+
+		// A prefixed aggregate suffix, e.g. "flag!~any".
+		if i := strings.LastIndex(segment, prefix+"~"); i > 0 {
+			if _, ok := aggregates[segment[i+len(prefix)+1:]]; ok && !isOperator(segment[:i]) {
+				segments = append(segments, segment[:i]+segment[i+len(prefix):])
+
+				continue
+			}
+		}
+
+		if isOperator(segment) {
+			segment = literalKey + segment
+		}
+
+		segments = append(segments, segment)
+	}
+
+	return strings.Join(segments, ".")
+}
+
+// isOperator reports whether segment, taken bare, isn't a plain object key.
+func isOperator(segment string) bool {
+	switch segment {
+	case "", "@", "%", "#", "#1", "$", "^", "*", "**", "[]", "[*]", "&now", "&type", "&keys", "&exists":
+		return true
+	}
+
+	if _, _, ok := splitAggregate(segment); ok {
+		return true
+	}
+
+	if _, ok := parseIndex(segment); ok {
+		return true
+	}
+
+	return strings.HasPrefix(segment, "$") || parseFilter(segment) != nil || parseWindow(segment) != nil
+}
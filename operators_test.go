@@ -0,0 +1,146 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestParseParamsOperatorPrefix(t *testing.T) {
+	data := json.RawMessage(`{
+		"@": "at",
+		"#": "hash",
+		"[]": "brackets",
+		"items": [{"v": 1, "flag": true}, {"v": 2, "flag": false}]
+	}`)
+
+	testTable := []struct {
+		name        string
+		meta        []jparser.MetaData
+		expectedRes []jparser.RawMessageSet
+	}{
+		{
+			name: "Unprefixed operators are keys",
+			meta: []jparser.MetaData{
+				{Path: "@", ParamID: "at"},
+				{Path: "#", ParamID: "hash"},
+				{Path: "[]", ParamID: "brackets"},
+			},
+			expectedRes: []jparser.RawMessageSet{
+				{
+					"at":       json.RawMessage(`"at"`),
+					"hash":     json.RawMessage(`"hash"`),
+					"brackets": json.RawMessage(`"brackets"`),
+				},
+			},
+		},
+		{
+			name: "Prefixed operators",
+			meta: []jparser.MetaData{
+				{Path: "@", ParamID: "at"},
+				{Path: "items.![].!@", ParamID: "index"},
+				{Path: "items.![].!#", ParamID: "count"},
+				{Path: "items.![].v", ParamID: "v"},
+			},
+			expectedRes: []jparser.RawMessageSet{
+				{
+					"at":    json.RawMessage(`"at"`),
+					"index": json.RawMessage(`0`),
+					"count": json.RawMessage(`2`),
+					"v":     json.RawMessage(`1`),
+				},
+				{
+					"at":    json.RawMessage(`"at"`),
+					"index": json.RawMessage(`1`),
+					"count": json.RawMessage(`2`),
+					"v":     json.RawMessage(`2`),
+				},
+			},
+		},
+		{
+			name: "Prefixed aggregate",
+			meta: []jparser.MetaData{
+				{Path: "items.![].flag!~any", ParamID: "any"},
+			},
+			expectedRes: []jparser.RawMessageSet{
+				{"any": json.RawMessage(`true`)},
+			},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := jparser.ParseParamsWithOptions(data, test.meta, jparser.Options{OperatorPrefix: "!"})
+			if err != nil {
+				t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+				return
+			}
+
+			if !reflect.DeepEqual(result, test.expectedRes) {
+				t.Errorf("ParseParamsWithOptions() got result = %v, expectedRes = %v", result, test.expectedRes)
+			}
+		})
+	}
+}
+
+func TestParseParamsOperatorPrefixDuplicates(t *testing.T) {
+	data := json.RawMessage(`{"@": {"k": 1, "k": 2}, "#": "hash", "[]": "brackets"}`)
+
+	testTable := []struct {
+		name        string
+		meta        []jparser.MetaData
+		expectedRes []jparser.RawMessageSet
+		expectedKey string
+	}{
+		{
+			name: "Duplicate ParamID on literal keys",
+			meta: []jparser.MetaData{
+				{Path: "#", ParamID: "v"},
+				{Path: "[]", ParamID: "v"},
+			},
+			expectedRes: []jparser.RawMessageSet{
+				{"v": json.RawMessage(`["hash","brackets"]`)},
+			},
+		},
+		{
+			name:        "Duplicate key under a literal key",
+			meta:        []jparser.MetaData{{Path: "@.k", ParamID: "k"}},
+			expectedKey: "k",
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := jparser.ParseParamsWithOptions(data, test.meta, jparser.Options{
+				OperatorPrefix:      "!",
+				DetectDuplicateKeys: true,
+				DuplicateParamID:    jparser.DuplicateArray,
+			})
+			if test.expectedKey == "" {
+				if err != nil {
+					t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+				}
+
+				if !reflect.DeepEqual(result, test.expectedRes) {
+					t.Errorf("ParseParamsWithOptions() got result = %s, expectedRes = %s", result, test.expectedRes)
+				}
+
+				return
+			}
+
+			var dupErr *jparser.DuplicateKeyError
+			if !errors.As(err, &dupErr) || dupErr.Key != test.expectedKey {
+				t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected *DuplicateKeyError for %q",
+					err, test.expectedKey)
+			}
+
+			var unmarshalErr *jparser.UnmarshalError
+			if !errors.As(err, &unmarshalErr) || unmarshalErr.Path != "@" || unmarshalErr.ParamID() != "k" {
+				t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected *UnmarshalError for k at @", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package jparser
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// parallel reports whether the elements iterated by g over n are parsed
+// concurrently, see Options.Parallelism.
+func (p *parser) parallel(n *node, g *group) bool {
+	return p.opts.Parallelism > 1 && n.parent == nil && g.segment == "[]"
+}
+
+// parseElements parses the elements of the root array n over a pool of
+// workers and returns their sets in element order.
+//
+// Each worker parses with a copy of p, so that schemas are cached per
+// worker. The params resolved and the errors collected for each element are
+// merged back into p in element order once all workers are done.
+func (p *parser) parseElements(n *node, g *group, elements elementSet) ([]RawMessageSet, error) {
+	length := len(elements.values)
+
+	var (
+		results  = make([][]RawMessageSet, length)
+		errs     = make([]error, length)
+		resolved = make([]map[string]bool, length)
+		collect  = make([][]error, length)
+		indices  = make(chan int)
+		// failed is the lowest index of an element failing to parse, so
+		// that the error returned is the one of the sequential path.
+		failed atomic.Int64
+		wg     sync.WaitGroup
+	)
+
+	failed.Store(int64(length))
+
+	workers := p.opts.Parallelism
+	if workers > length {
+		workers = length
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			worker := &parser{opts: p.opts, declared: p.declared, discard: p.discard, ctx: p.ctx}
+
+			for k := range indices {
+				if int64(k) > failed.Load() {
+					continue
+				}
+
+				if p.resolved != nil {
+					worker.resolved = make(map[string]bool)
+					resolved[k] = worker.resolved
+				}
+
+				if p.errs != nil {
+					worker.errs = &collect[k]
+				}
+
+				if errs[k] = worker.canceled(); errs[k] == nil {
+					element, index, key := elements.at(n, worker.elementIndex(k, length))
+					results[k], errs[k] = worker.parseElement(element, index, key, g)
+				}
+
+				for errs[k] != nil {
+					first := failed.Load()
+					if int64(k) >= first || failed.CompareAndSwap(first, int64(k)) {
+						break
+					}
+				}
+			}
+		}()
+	}
+
+	for k := 0; k < length && int64(k) <= failed.Load(); k++ {
+		indices <- k
+	}
+
+	close(indices)
+	wg.Wait()
+
+	var res []RawMessageSet
+
+	for k := range results {
+		for paramID := range resolved[k] {
+			p.resolve(paramID)
+		}
+
+		if p.errs != nil {
+			*p.errs = append(*p.errs, collect[k]...)
+		}
+
+		if errs[k] != nil {
+			return nil, errs[k]
+		}
+
+		if p.discard {
+			continue
+		}
+
+		res = append(res, results[k]...)
+		if p.opts.MaxRows > 0 && len(res) > p.opts.MaxRows {
+			return nil, ErrTooManyRows
+		}
+	}
+
+	return res, nil
+}
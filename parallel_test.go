@@ -0,0 +1,91 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestParseParamsParallelism(t *testing.T) {
+	testTable := []struct {
+		name string
+		data json.RawMessage
+		meta []jparser.MetaData
+		opts jparser.Options
+	}{
+		{
+			name: "Synthetic array",
+			data: syntheticArray(1000),
+			meta: streamMeta,
+		},
+		{
+			name: "Shared prefix",
+			data: multipleElementsInArrayJSON,
+			meta: sharedPrefixMeta,
+		},
+		{
+			name: "Reversed with defaults",
+			data: multipleElementsInArrayJSON,
+			meta: []jparser.MetaData{
+				{Path: "[].inn", ParamID: "inn"},
+				{Path: "[].@", ParamID: "index"},
+				{Path: "[].IP.fio", ParamID: "fio", Default: json.RawMessage(`""`)},
+			},
+			opts: jparser.Options{ReverseArrays: true},
+		},
+		{
+			name: "Max rows",
+			data: syntheticArray(100),
+			meta: streamMeta,
+			opts: jparser.Options{MaxRows: 50},
+		},
+		{
+			name: "First error",
+			data: json.RawMessage(`[{"inn": "1"}, 2, {"inn": "3"}, 4]`),
+			meta: []jparser.MetaData{{Path: "[].inn", ParamID: "inn"}},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			expectedRes, expectedErr := jparser.ParseParamsWithOptions(testCase.data, testCase.meta, testCase.opts)
+
+			opts := testCase.opts
+			opts.Parallelism = 4
+
+			result, err := jparser.ParseParamsWithOptions(testCase.data, testCase.meta, opts)
+			if !reflect.DeepEqual(err, expectedErr) && !errors.Is(err, expectedErr) {
+				t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected \"%v\"", err, expectedErr)
+			}
+
+			if !reflect.DeepEqual(result, expectedRes) {
+				t.Errorf("ParseParamsWithOptions() got result = %s, expectedRes = %s", result, expectedRes)
+			}
+		})
+	}
+}
+
+func TestParseParamsParallelismUnmatched(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "[].inn", ParamID: "inn"},
+		{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+		{Path: "[].UL.okved", ParamID: "okved"},
+	}
+
+	p, err := jparser.NewParser(meta, jparser.Options{Parallelism: 4})
+	if err != nil {
+		t.Fatalf("NewParser() got error = \"%v\", expected nil", err)
+	}
+
+	_, unmatched, err := p.ParseParamsUnmatched(syntheticArray(10))
+	if err != nil {
+		t.Fatalf("ParseParamsUnmatched() got error = \"%v\", expected nil", err)
+	}
+
+	if expected := []string{"okved"}; !reflect.DeepEqual(unmatched, expected) {
+		t.Errorf("ParseParamsUnmatched() got unmatched = %v, expected %v", unmatched, expected)
+	}
+}
@@ -1,146 +1,1590 @@
 package jparser
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
 )
 
-type RawMessageSet map[string]json.RawMessage
+type RawMessageSet map[string]json.RawMessage
+
+type MetaData struct {
+	// Path is the dot-separated path of the bound value. An empty path, or
+	// an empty last segment as in "[].UL.", binds the raw JSON of the node
+	// reached, also alongside paths going below it. An empty key is written
+	// `[""]`.
+	Path string
+	// ParamID may be a template referencing fields of the object holding the
+	// value, e.g. "kpp_{parsedAddressRF.regionCode}", see TemplateError.
+	ParamID string
+	// Schema is an optional JSON Schema the bound value must satisfy. Only
+	// the type, enum, pattern, minimum, maximum, minLength and maxLength
+	// keywords are supported.
+	Schema json.RawMessage
+	// SchemaRef is a JSON Pointer to a schema inside Options.SchemaDocument,
+	// e.g. "#/definitions/inn". It is used when Schema is empty, and must
+	// point to a valid schema, see NewParser.
+	SchemaRef string
+	// Required makes parsing fail with a *MissingError when the path
+	// doesn't resolve, or with Options.DeferRequired, with a
+	// *ValidationError when a result set lacks the param.
+	Required bool
+	// Default, if not nil, is bound when the path doesn't resolve, so that
+	// every result set holds the param.
+	Default json.RawMessage
+	// Transform, if not nil, rewrites the value before it is bound, e.g. to
+	// normalize it. An error fails parsing with a *TransformError. Defaults
+	// aren't transformed.
+	Transform func(json.RawMessage) (json.RawMessage, error)
+}
+
+// Options tunes the behaviour of ParseParamsWithOptions.
+type Options struct {
+	// ReverseCollect makes "[*]" collect values from the last array element to the first.
+	ReverseCollect bool
+	// Clock returns the current time for the "&now" token. Defaults to time.Now.
+	Clock func() time.Time
+	// ParentPathSuffix, when set, binds the resolved path of the container
+	// holding each value under ParamID+ParentPathSuffix, e.g. "[0].UL.branches.[2]".
+	ParentPathSuffix string
+	// EnclosingObjectSuffix, when set, binds the raw JSON of the nearest
+	// object holding each value under ParamID+EnclosingObjectSuffix.
+	EnclosingObjectSuffix string
+	// ReverseArrays iterates "[]" from the last element to the first. The "@"
+	// token still reports the original index of each element.
+	ReverseArrays bool
+	// MaxElementDepth limits the nesting depth of each array element recursed
+	// into by "[]" and "[*]". Zero means unlimited.
+	MaxElementDepth int
+	// Codec is the JSON implementation used for decoding. Defaults to StdCodec.
+	Codec JSONCodec
+	// EmptyMetaPerElement makes a call with empty meta over a JSON array
+	// return one empty set per element instead of a single empty set.
+	EmptyMetaPerElement bool
+	// PresenceSuffix, when set, binds {"present":N,"total":M} under
+	// ParamID+PresenceSuffix for params collected with "[*]" or reduced with
+	// a "~" aggregate: the number of array elements the param was found in
+	// and the array length.
+	PresenceSuffix string
+	// Aliases maps names to path prefixes referenced as "$name" segments,
+	// see DefineAlias.
+	Aliases map[string]string
+	// MinPopulatedParams rejects result sets holding fewer than this many of
+	// the declared params with an *UnderpopulatedError, or drops them if
+	// DropUnderpopulated is set. Zero disables the check.
+	MinPopulatedParams int
+	DropUnderpopulated bool
+	// StrictBoolAggregates makes "~any" and "~all" fail on elements missing
+	// the value or holding a non-boolean one, instead of skipping them.
+	StrictBoolAggregates bool
+	// SchemaDocument holds the schemas referenced by MetaData.SchemaRef.
+	SchemaDocument json.RawMessage
+	// Encoding of the input, which is transcoded to UTF-8 before parsing.
+	// Input starting with a UTF-8 or UTF-16 byte order mark is always
+	// transcoded according to it.
+	Encoding encoding.Encoding
+	// ProvenanceSuffix, when set, binds the resolved path of the deepest
+	// node found under ParamID+ProvenanceSuffix for each param whose path
+	// doesn't resolve, e.g. "[0].contactPhones" when only "count" is missing
+	// from it, or "" when nothing on the path exists.
+	ProvenanceSuffix string
+	// OffsetSuffix, when set, binds the [start, end) byte range of each
+	// value in the document under ParamID+OffsetSuffix as a JSON array, e.g.
+	// [12,24]. Offsets are into the document as parsed, after Encoding and
+	// Preprocess. Values of tokens such as "#", and values streamed by
+	// (*Parser).ParseStream, have none.
+	OffsetSuffix string
+	// FilterCompare tunes how "[?key=value]" filters compare strings.
+	FilterCompare CompareOptions
+	// Separator separates the segments of meta paths and aliases instead of
+	// ".", so that keys holding dots need no escaping, e.g. "[]/UL/branches".
+	// Templated ParamIDs and Preprocess steps still use ".".
+	Separator string
+	// OperatorPrefix, when set, must precede every operator in meta paths,
+	// e.g. "!@" and "!#" with "!", and aggregate suffixes, e.g. "flag!~any".
+	// Unprefixed segments are always object keys, so that keys such as "@"
+	// or "#" can be addressed.
+	OperatorPrefix string
+	// Preprocess steps rewrite the input in order before it is parsed, see
+	// RenameKey and UnwrapPrefix.
+	Preprocess []Step
+	// DisallowTrailingData fails parsing with ErrTrailingData when the input
+	// holds anything but whitespace after the first JSON value.
+	DisallowTrailingData bool
+	// MixedArrays sets how "[]" treats scalar elements of arrays whose
+	// elements are addressed by key.
+	MixedArrays MixedPolicy
+	// MixedValueParamID binds scalar elements with MixedAsValue.
+	MixedValueParamID string
+	// SkipMalformedLines makes ParseNDJSON skip lines that aren't valid JSON
+	// instead of returning a *LineError. Other failures, like a missing
+	// required param, are still returned.
+	SkipMalformedLines bool
+	// Strict fails parsing when a path doesn't resolve, with a
+	// *MissingError as if every param were Required, or traverses a value of
+	// the wrong JSON type, with a *ContainerError.
+	Strict bool
+	// MaxDescentDepth bounds the nesting depth searched by "**", failing
+	// with an *ElementDepthError beyond it. Defaults to 100.
+	MaxDescentDepth int
+	// CaseInsensitive matches object keys of meta paths ignoring case when
+	// no key matches exactly. Among several keys differing only in case, the
+	// first in sorted order is used, e.g. "INN" before "Inn".
+	CaseInsensitive bool
+	// MaxRows fails parsing with ErrTooManyRows as soon as the result sets,
+	// multiplied by every array iterated, would number more than MaxRows.
+	// Zero means unlimited.
+	MaxRows int
+	// Distinct drops result sets equal to an earlier one, comparing values
+	// as JSON regardless of whitespace and object key order.
+	Distinct bool
+	// DuplicateParamID sets how the values of meta entries sharing a
+	// ParamID are merged. Defaults to DuplicateLast.
+	DuplicateParamID DuplicatePolicy
+	// CollectErrors makes parsing go on past an error in one branch of the
+	// meta, so that it fails with a *MultiError holding the errors of every
+	// branch instead of the first one. Result sets aren't returned with it.
+	CollectErrors bool
+	// AutoRoot applies paths not starting with "[" under an implicit
+	// leading "[]" when the document is an array, so that the same meta
+	// extracts from a single object and from an array of them.
+	AutoRoot bool
+	// FirstOnly makes "*" iterate only the value of the first key in sorted
+	// order, and "**" stop at the first value where a param resolves, in
+	// document order with object keys sorted, so that they yield a single
+	// row instead of one per match.
+	FirstOnly bool
+	// MaxDepth bounds the number of path segments traversed from the root,
+	// including those descended by "**", failing with ErrMaxDepthExceeded
+	// beyond it. Defaults to 1000.
+	MaxDepth int
+	// MaxArrayElements, when positive, caps the elements iterated by "[]",
+	// filters, windows and "*" at the first MaxArrayElements, for sampling
+	// large arrays. Tokens such as "#", "$" and aggregates still cover every
+	// element.
+	MaxArrayElements int
+	// ScalarAsArray makes "[]", filters and windows iterate a value that
+	// isn't an array, such as a single object where an API may also return
+	// an array of them, as a one-element array. A null is still empty.
+	ScalarAsArray bool
+	// ObjectAsArray makes "[]" iterate the values of an object in key order,
+	// as "*" does, for feeds keying their entries by ID instead of listing
+	// them in an array. The "%" token binds the key of each value.
+	ObjectAsArray bool
+	// IndexLabelPrefix, when set, binds in every row the index of the
+	// element of each array iterated by "[]" under IndexLabelPrefix followed
+	// by the meta path of the array, e.g. "@[].UL.branches.[]" with "@", so
+	// that rows of independent arrays tell which elements they combine.
+	IndexLabelPrefix string
+	// Parallelism parses the elements of a root array iterated by "[]" over
+	// up to Parallelism goroutines. The result sets keep their order. Values
+	// below 2 parse sequentially.
+	Parallelism int
+	// JSONC accepts input holding "//" and "/* */" comments and trailing
+	// commas, which are blanked out before parsing, after Encoding. Offsets
+	// are unchanged by it.
+	JSONC bool
+	// NestSeparator, when set, groups params whose ParamID holds it into
+	// nested objects, e.g. "address.zip" and "address.city" with "." into
+	// {"address": {"city": ..., "zip": ...}}, at every level. A ParamID that
+	// also names a param, e.g. "address", keeps the params below it flat.
+	NestSeparator string
+	// DisableMemoization decodes an object or array every time a path goes
+	// through it, instead of once per parse, keeping less of the document
+	// decoded in memory at the cost of time when paths share a prefix.
+	DisableMemoization bool
+	// DetectDuplicateKeys fails parsing with an *UnmarshalError wrapping a
+	// *DuplicateKeyError when an object traversed on the path of a param
+	// repeats a key, which would otherwise silently bind the last value.
+	DetectDuplicateKeys bool
+	// BestEffort makes ParseParams parse the valid prefix of a malformed
+	// document and return its result sets together with a *PartialError
+	// instead of failing, and ParseStream pass them to its callback before
+	// returning the *PartialError. The valid prefix holds the JSON values
+	// read before the first syntax error, in a copy of the document closing
+	// the objects and arrays still open there. A key whose value is
+	// incomplete is left out, as is anything after the first JSON value.
+	// Other methods fail with the *PartialError.
+	BestEffort bool
+	// DeferRequired checks MetaData.Required on the result sets rather than
+	// while traversing, failing with a *ValidationError listing every set
+	// lacking a required param instead of a *MissingError for the first
+	// one. Templated ParamIDs aren't checked. ParseStream and
+	// (*Plan).Iterator check the sets of each root array element as they
+	// are built. Strict still fails while traversing.
+	DeferRequired bool
+}
+
+// MixedPolicy is the treatment of scalar elements among objects, see
+// Options.MixedArrays.
+type MixedPolicy int
+
+const (
+	// MixedError fails parsing with an *UnmarshalError.
+	MixedError MixedPolicy = iota
+	// MixedSkip ignores scalar elements.
+	MixedSkip
+	// MixedAsValue binds a scalar element under Options.MixedValueParamID
+	// instead of the params addressed in it.
+	MixedAsValue
+)
+
+type parser struct {
+	opts    Options
+	schemas map[string]*schema
+	// declared holds the ParamIDs of the meta, which templated ParamIDs
+	// must not resolve to.
+	declared map[string]bool
+	// discard skips building result sets when only validity matters.
+	discard bool
+	// seen holds the canonical form of the sets returned with
+	// Options.Distinct.
+	seen map[string]bool
+	// resolved, if not nil, records the ParamIDs of the meta entries whose
+	// paths resolved, see ParseParamsUnmatched.
+	resolved map[string]bool
+	// explained, if not nil, records the paths of the values bound, see
+	// ExplainPaths.
+	explained *[]string
+	// errs, if not nil, collects errors for Options.CollectErrors.
+	errs *[]error
+	// ctx, if not nil, aborts parsing once done, checked before each array
+	// element.
+	ctx context.Context
+	// finished is the number of result sets returned by finish, from which
+	// checkRequiredSets numbers the sets of streamed elements, and populated
+	// the number checked by checkPopulated, from which it numbers them.
+	finished, populated int
+	// generalPath is Parser.generalPath.
+	generalPath bool
+}
+
+// node is a JSON value being traversed together with its resolved location
+// in the source document, e.g. "[0].UL.branches.[2]".
+//
+// The decoded object or array is memoized on the node, so that params
+// sharing a prefix decode every subtree once per parse, see
+// Options.DisableMemoization.
+type node struct {
+	data   json.RawMessage
+	path   string
+	parent *node
+	object RawMessageSet
+	array  []json.RawMessage
+	// depth is the number of segments from the root, see Options.MaxDepth.
+	depth int
+	// segment is the key or "[N]" index of n in its parent.
+	segment string
+	// offsets memoizes the start of the members of n, see offset.
+	offsets     map[string]int
+	offsetsOnce sync.Once
+}
+
+func (n *node) child(data json.RawMessage, segment string) *node {
+	path := segment
+	if n.path != "" {
+		path = n.path + "." + segment
+	}
+
+	return &node{data: data, path: path, parent: n, depth: n.depth + 1, segment: segment}
+}
+
+// enclosing returns the node holding n for the "^" token: its parent, or for
+// an element of an array held by another node, that node, so that "^" from
+// an element of "UL.branches" reaches UL. The root has none.
+func (n *node) enclosing() *node {
+	parent := n.parent
+	if parent != nil && isArray(parent.data) && parent.parent != nil {
+		parent = parent.parent
+	}
+
+	if parent == nil {
+		return &node{}
+	}
+
+	return parent
+}
+
+// ParseParams extracts the values addressed by meta from data. Empty data or
+// empty meta yield a single empty set, see Options.EmptyMetaPerElement.
+//
+// A key holding null binds the JSON null, while a path that doesn't resolve
+// binds nothing unless MetaData.Default is set.
+//
+// The order of the result sets is deterministic: the sets of paths differing
+// in a segment are combined in the order the segment first appears in meta,
+// the sets of earlier segments varying slowest, and array elements follow
+// document order.
+func ParseParams(data json.RawMessage, meta []MetaData) ([]RawMessageSet, error) {
+	return ParseParamsWithOptions(data, meta, Options{})
+}
+
+func ParseParamsWithOptions(data json.RawMessage, meta []MetaData, opts Options) ([]RawMessageSet, error) {
+	p, err := NewParser(meta, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.ParseParams(data)
+}
+
+// Parser holds meta compiled once for parsing many documents. It is safe for
+// concurrent use.
+type Parser struct {
+	opts Options
+	meta []MetaData
+	root *level
+	// arrayRoot is the meta compiled for array documents with AutoRoot.
+	arrayRoot *level
+	declared  map[string]bool
+	// duplicated holds the ParamIDs shared by several meta entries.
+	duplicated map[string]bool
+	// generalPath disables the fast path of plain levels, see fill. Tests
+	// set it to check the fast path against the general one.
+	generalPath bool
+}
+
+// ParseParamsContext is ParseParams stopping with ctx.Err() once ctx is done,
+// checked before each array element.
+func ParseParamsContext(ctx context.Context, data json.RawMessage, meta []MetaData) ([]RawMessageSet, error) {
+	return ParseParamsContextWithOptions(ctx, data, meta, Options{})
+}
+
+func ParseParamsContextWithOptions(
+	ctx context.Context, data json.RawMessage, meta []MetaData, opts Options,
+) ([]RawMessageSet, error) {
+	p, err := NewParser(meta, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.ParseParamsContext(ctx, data)
+}
+
+// ParseParamsUnmatched is ParseParams also returning the ParamIDs of the meta
+// entries whose paths resolved nowhere in data, in meta order. Values bound
+// from MetaData.Default don't count as resolved.
+func ParseParamsUnmatched(data json.RawMessage, meta []MetaData) ([]RawMessageSet, []string, error) {
+	p, err := NewParser(meta, Options{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return p.ParseParamsUnmatched(data)
+}
+
+// NewParser expands the aliases in meta and compiles it for opts. A
+// MetaData.SchemaRef not pointing to a valid schema fails with a *MetaError.
+func NewParser(meta []MetaData, opts Options) (*Parser, error) {
+	meta, err := expandMetaAliases(
+		applyOperatorPrefix(applySeparator(meta, opts.Separator), opts.OperatorPrefix),
+		prefixedAliases(separatedAliases(opts.Aliases, opts.Separator), opts.OperatorPrefix))
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := resolveSchemaRefs(meta, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	renamed, err := renameDuplicates(existsDefaults(resolved), opts.DuplicateParamID)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Parser{
+		opts: opts, meta: meta, root: compile(renamed).label(""),
+		declared: make(map[string]bool, len(meta)), duplicated: make(map[string]bool),
+	}
+
+	for _, m := range meta {
+		if !isTemplate(m.ParamID) {
+			p.duplicated[m.ParamID] = p.declared[m.ParamID]
+			p.declared[m.ParamID] = true
+		}
+	}
+
+	if opts.AutoRoot {
+		p.arrayRoot = compile(implicitArray(renamed)).label("")
+	}
+
+	return p, nil
+}
+
+// implicitArray prefixes the paths of meta not starting with "[" with "[]".
+func implicitArray(meta []MetaData) []MetaData {
+	res := make([]MetaData, len(meta))
+
+	for i, m := range meta {
+		if m.Path != "" && (!strings.HasPrefix(m.Path, "[") || quotedKeyEnd(m.Path) > 0) {
+			m.Path = "[]." + m.Path
+		}
+
+		res[i] = m
+	}
+
+	return res
+}
+
+// newRun returns the state of a single parse.
+func (p *Parser) newRun() *parser {
+	pp := &parser{opts: p.opts, declared: p.declared, generalPath: p.generalPath}
+	if p.opts.CollectErrors {
+		pp.errs = &[]error{}
+	}
+
+	return pp
+}
+
+// prepare transcodes data, strips JSONC and runs the Preprocess steps over
+// it. With Options.BestEffort, the valid prefix of malformed data is returned
+// together with a *PartialError.
+func (p *Parser) prepare(data json.RawMessage) (json.RawMessage, error) {
+	data, err := transcode(data, p.opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.prepareTranscoded(data)
+}
+
+// prepareTranscoded is prepare for data already transcoded to UTF-8.
+func (p *Parser) prepareTranscoded(data json.RawMessage) (json.RawMessage, error) {
+	if p.opts.JSONC {
+		data = stripJSONC(data)
+	}
+
+	var partial error
+
+	if p.opts.BestEffort && len(bytes.TrimSpace(data)) > 0 && !json.Valid(data) {
+		prefix, offset, err := validPrefix(data)
+		data, partial = prefix, &PartialError{offset, err}
+	}
+
+	if err := p.checkTrailing(data); err != nil {
+		return nil, err
+	}
+
+	data, err := p.preprocess(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, partial
+}
+
+// level returns the compiled meta for a document, array or not.
+func (p *Parser) level(array bool) *level {
+	if array && p.arrayRoot != nil {
+		return p.arrayRoot
+	}
+
+	return p.root
+}
+
+// ParseParams is ParseParamsWithOptions with the meta and options of p.
+func (p *Parser) ParseParams(data json.RawMessage) ([]RawMessageSet, error) {
+	return p.ParseParamsContext(context.Background(), data)
+}
+
+// ParseParamsContext is ParseParamsContextWithOptions with the meta and
+// options of p.
+func (p *Parser) ParseParamsContext(ctx context.Context, data json.RawMessage) ([]RawMessageSet, error) {
+	pp := p.newRun()
+	pp.ctx = ctx
+
+	res, err := p.parse(pp, data)
+	if err != nil && !errors.As(err, new(*PartialError)) {
+		return nil, restoreParamID(err)
+	}
+
+	return res, err
+}
+
+// ParseParamsUnmatched is ParseParamsUnmatched with the meta and options of
+// p.
+func (p *Parser) ParseParamsUnmatched(data json.RawMessage) ([]RawMessageSet, []string, error) {
+	pp := p.newRun()
+	pp.resolved = make(map[string]bool, len(p.meta))
+
+	res, err := p.parse(pp, data)
+	if err != nil {
+		return nil, nil, restoreParamID(err)
+	}
+
+	resolved := make(map[string]bool, len(pp.resolved))
+	for paramID := range pp.resolved {
+		original, _ := originalParamID(paramID)
+		resolved[original] = true
+	}
+
+	var unmatched []string
+
+	for _, m := range p.meta {
+		if !resolved[m.ParamID] {
+			resolved[m.ParamID] = true
+			unmatched = append(unmatched, m.ParamID)
+		}
+	}
+
+	return res, unmatched, nil
+}
+
+func (p *Parser) parse(pp *parser, data json.RawMessage) ([]RawMessageSet, error) {
+	data, partial := p.prepare(data)
+	if partial != nil && !errors.As(partial, new(*PartialError)) {
+		return nil, partial
+	}
+
+	res, err := pp.parseParams(&node{data: data}, p.level(isArray(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	if res, err = p.finish(pp, res); err != nil {
+		return nil, err
+	}
+
+	return res, partial
+}
+
+// Validate runs the same traversal and checks as ParseParams, but doesn't
+// build result sets. It returns the first error found.
+func Validate(data json.RawMessage, meta []MetaData) error {
+	return ValidateWithOptions(data, meta, Options{})
+}
+
+func ValidateWithOptions(data json.RawMessage, meta []MetaData, opts Options) error {
+	p, err := NewParser(meta, opts)
+	if err != nil {
+		return err
+	}
+
+	return restoreParamID(p.validate(data))
+}
+
+func (p *Parser) validate(data json.RawMessage) error {
+	data, err := p.prepare(data)
+	if err != nil {
+		return err
+	}
+
+	// MinPopulatedParams and DeferRequired are checked on the result sets,
+	// so they must be kept.
+	pp := p.newRun()
+	pp.discard = p.opts.MinPopulatedParams <= 0 && !p.opts.DeferRequired
+
+	res, err := pp.parseParams(&node{data: data}, p.level(isArray(data)))
+	if err != nil {
+		return err
+	}
+
+	if err := pp.collected(); err != nil {
+		return err
+	}
+
+	if res, err = pp.checkPopulated(p.mergeDuplicates(res), p.meta); err != nil {
+		return err
+	}
+
+	return pp.checkRequiredSets(res, p.meta)
+}
+
+// nolint:wsl
+func (p *parser) parseParams(n *node, lvl *level) ([]RawMessageSet, error) {
+	meta := lvl.meta
+
+	if len(meta) == 0 && p.opts.EmptyMetaPerElement && n.parent == nil {
+		if sliceJSON, err := p.array(n); err == nil && len(sliceJSON) > 0 {
+			res := make([]RawMessageSet, len(sliceJSON))
+			for i := range res {
+				res[i] = RawMessageSet{}
+			}
+
+			return res, nil
+		}
+	}
+
+	if len(n.data) == 0 || len(meta) == 0 {
+		return []RawMessageSet{{}}, nil
+	}
+
+	if err := p.checkDepth(n); err != nil {
+		return nil, err
+	}
+
+	if lvl.plain && !p.generalPath {
+		res := RawMessageSet{}
+		if err := p.fill(n, lvl, res); err != nil {
+			return nil, err
+		}
+
+		return []RawMessageSet{res}, nil
+	}
+
+	res, err := p.bindAll(n, lvl.leaves)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, g := range lvl.groups {
+		currentRes, err := p.unmarshalNextLevel(n, g)
+		if err != nil {
+			if p.errs == nil {
+				return nil, err
+			}
+
+			*p.errs = append(*p.errs, err)
+
+			continue
+		}
+
+		if !p.discard {
+			if res, err = p.product(res, currentRes); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// nolint:nestif,gocognit,cyclop
+func (p *parser) unmarshalNextLevel(n *node, g *group) ([]RawMessageSet, error) {
+	meta, currentPath := g.meta, g.segment
+
+	if currentPath == "[*]" {
+		return p.collect(n, g)
+	}
+
+	if currentPath == "&now" {
+		now := json.RawMessage(strconv.Quote(p.now().Format(time.RFC3339Nano)))
+
+		res := RawMessageSet{}
+		for _, m := range meta {
+			res[m.ParamID] = now
+			p.resolve(m.ParamID)
+		}
+
+		return []RawMessageSet{res}, nil
+	}
+
+	switch currentPath {
+	case "#":
+		return p.length(n, g)
+	case "&type":
+		return p.bindAll(n.child(json.RawMessage(strconv.Quote(jsonType(n.data))), currentPath), g.meta)
+	case "&keys":
+		return p.keys(n, g)
+	case "&exists":
+		return p.bindAll(n.child(json.RawMessage("true"), currentPath), g.meta)
+	}
+
+	if g.iterates() {
+		var resAll, resList []RawMessageSet
+
+		if g.all == nil {
+			resAll = []RawMessageSet{{}}
+		} else {
+			set, err := p.bind(n, *g.all)
+			if err != nil {
+				return nil, err
+			}
+
+			resAll = []RawMessageSet{set}
+		}
+
+		container := "array"
+		if p.iteratesObject(n, g) {
+			container = "object"
+		}
+
+		if container == "object" || !p.opts.ScalarAsArray {
+			if err := p.checkContainer(n, meta, container); err != nil {
+				return nil, err
+			}
+		}
+
+		elements, err := p.elements(n, g)
+		if err != nil {
+			return nil, p.unmarshalError(err, meta[0].ParamID, n)
+		}
+
+		sliceJSON := elements.values
+
+		if g.count != nil {
+			p.resolve(g.count.ParamID)
+			resAll = cartesianProduct(resAll,
+				[]RawMessageSet{{g.count.ParamID: json.RawMessage(strconv.Itoa(len(sliceJSON)))}})
+		}
+
+		if g.singleton != nil {
+			p.resolve(g.singleton.ParamID)
+			resAll = cartesianProduct(resAll,
+				[]RawMessageSet{{g.singleton.ParamID: json.RawMessage(strconv.FormatBool(len(sliceJSON) == 1))}})
+		}
+
+		if g.last != nil {
+			lastRes, err := p.last(n, g, elements)
+			if err != nil {
+				return nil, err
+			}
+
+			resAll = cartesianProduct(resAll, lastRes)
+		}
+
+		if len(g.aggregates) > 0 {
+			aggregateRes, err := p.aggregate(n, sliceJSON, g.aggregates)
+			if err != nil {
+				return nil, err
+			}
+
+			resAll = cartesianProduct(resAll, aggregateRes)
+		}
+
+		if len(sliceJSON) == 0 {
+			if err := p.checkRequired(g.base.meta, n.child(nil, currentPath)); err != nil {
+				return nil, err
+			}
+
+			if g.index != nil {
+				if err := p.checkRequired([]MetaData{*g.index}, n.child(nil, currentPath)); err != nil {
+					return nil, err
+				}
+			}
+
+			resList = []RawMessageSet{p.unresolved(g.base.meta, n)}
+		}
+
+		if p.opts.MaxArrayElements > 0 {
+			elements = elements.head(p.opts.MaxArrayElements)
+			sliceJSON = elements.values
+		}
+
+		if g.index != nil || g.key != nil || len(g.base.meta) > 0 {
+			if len(sliceJSON) > 1 && p.parallel(n, g) {
+				if resList, err = p.parseElements(n, g, elements); err != nil {
+					return nil, err
+				}
+			} else {
+				for k := range sliceJSON {
+					if err := p.canceled(); err != nil {
+						return nil, err
+					}
+
+					element, index, key := elements.at(n, p.elementIndex(k, len(sliceJSON)))
+
+					currentRes, err := p.parseElement(element, index, key, g)
+					if err != nil {
+						return nil, err
+					}
+
+					if p.discard {
+						continue
+					}
+
+					resList = append(resList, currentRes...)
+					if p.opts.MaxRows > 0 && len(resList) > p.opts.MaxRows {
+						return nil, ErrTooManyRows
+					}
+				}
+			}
+		}
+
+		if p.discard || resList == nil {
+			resList = []RawMessageSet{{}}
+		}
+
+		return p.product(resList, resAll)
+	}
+
+	if g.element != nil {
+		return p.element(n, g)
+	}
+
+	if currentPath == "**" {
+		return p.descend(n, g)
+	}
+
+	if currentPath == "^" {
+		return p.parseParams(n.enclosing(), g.next)
+	}
+
+	if err := p.checkContainer(n, meta, "object"); err != nil {
+		return nil, err
+	}
+
+	rawMessage, err := p.object(n)
+	if err != nil {
+		return nil, p.unmarshalError(err, meta[0].ParamID, n)
+	}
+
+	key := g.name
+
+	value, ok := rawMessage[key]
+	if !ok && p.opts.CaseInsensitive {
+		key, value, ok = lookupKey(rawMessage, key)
+	}
+
+	if !ok {
+		if err := p.checkRequired(meta, n.child(nil, key)); err != nil {
+			return nil, err
+		}
+
+		return []RawMessageSet{p.unresolved(meta, n)}, nil
+	}
+
+	res, err := p.parseParams(n.child(value, key), g.next)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// fill binds the meta of the plain level lvl below n into res, as
+// parseParams does, but without building the single sets of every level and
+// merging them.
+func (p *parser) fill(n *node, lvl *level, res RawMessageSet) error {
+	for _, m := range lvl.leaves {
+		set, err := p.bind(n, m)
+		if err != nil {
+			return err
+		}
+
+		for paramID, value := range set {
+			res[paramID] = value
+		}
+	}
+
+	for _, g := range lvl.groups {
+		if err := p.fillKey(n, g, res); err != nil {
+			if p.errs == nil {
+				return err
+			}
+
+			*p.errs = append(*p.errs, err)
+		}
+	}
+
+	return nil
+}
+
+// fillKey is fill for the key group g, as unmarshalNextLevel.
+func (p *parser) fillKey(n *node, g *group, res RawMessageSet) error {
+	if err := p.checkContainer(n, g.meta, "object"); err != nil {
+		return err
+	}
+
+	object, err := p.object(n)
+	if err != nil {
+		return p.unmarshalError(err, g.meta[0].ParamID, n)
+	}
+
+	key := g.name
+
+	value, ok := object[key]
+	if !ok && p.opts.CaseInsensitive {
+		key, value, ok = lookupKey(object, key)
+	}
+
+	if !ok {
+		if err := p.checkRequired(g.meta, n.child(nil, key)); err != nil {
+			return err
+		}
+
+		for paramID, value := range p.unresolved(g.meta, n) {
+			res[paramID] = value
+		}
+
+		return nil
+	}
+
+	child := n.child(value, key)
+	if len(child.data) == 0 {
+		return nil
+	}
+
+	if err := p.checkDepth(child); err != nil {
+		return err
+	}
+
+	return p.fill(child, g.next, res)
+}
+
+// last binds the last element of the array n for the "$" token of g. Like
+// "#", it is bound once per array and combined with every element row.
+func (p *parser) last(n *node, g *group, elements elementSet) ([]RawMessageSet, error) {
+	if len(elements.values) == 0 {
+		if err := p.checkRequired([]MetaData{*g.last}, n.child(nil, g.segment)); err != nil {
+			return nil, err
+		}
+
+		return []RawMessageSet{p.unresolved([]MetaData{*g.last}, n)}, nil
+	}
+
+	element, _, _ := elements.at(n, len(elements.values)-1)
+
+	set, err := p.bind(element, *g.last)
+	if err != nil {
+		return nil, err
+	}
+
+	return []RawMessageSet{set}, nil
+}
+
+// element extracts the meta of the "[N]" group g from the selected element
+// of the array n.
+func (p *parser) element(n *node, g *group) ([]RawMessageSet, error) {
+	if err := p.checkContainer(n, g.meta, "array"); err != nil {
+		return nil, err
+	}
 
-type MetaData struct {
-	Path    string
-	ParamID string
+	sliceJSON, err := p.array(n)
+	if err != nil {
+		return nil, p.unmarshalError(err, g.meta[0].ParamID, n)
+	}
+
+	i := *g.element
+	if i < 0 {
+		i += len(sliceJSON)
+	}
+
+	if i < 0 || i >= len(sliceJSON) {
+		if err := p.checkRequired(g.meta, n.child(nil, g.segment)); err != nil {
+			return nil, err
+		}
+
+		return []RawMessageSet{p.unresolved(g.meta, n)}, nil
+	}
+
+	return p.parseParams(n.child(sliceJSON[i], indexSegment(i)), g.next)
 }
 
-type UnmarshalError struct {
-	err     error
-	paramID string
+// parseElement extracts the per-element meta of the "[]" group g from the
+// array element n at index i, or the object value n under key for "*".
+func (p *parser) parseElement(n *node, i int, key string, g *group) ([]RawMessageSet, error) {
+	if err := p.checkElementDepth(n); err != nil {
+		return nil, err
+	}
+
+	var (
+		res []RawMessageSet
+		err error
+	)
+
+	// Scalar values of "*" have no keys to match, as with MixedSkip.
+	switch {
+	case len(g.base.meta) == 0 || !isScalar(n.data) || (p.opts.MixedArrays == MixedError && g.segment != "*"):
+		res, err = p.parseParams(n, g.base)
+		if err != nil {
+			return nil, err
+		}
+	case p.opts.MixedArrays != MixedAsValue:
+		return nil, nil
+	default:
+		res = []RawMessageSet{{p.opts.MixedValueParamID: n.data}}
+	}
+
+	element := RawMessageSet{}
+
+	if g.index != nil {
+		element[g.index.ParamID] = json.RawMessage(strconv.Itoa(i))
+		p.resolve(g.index.ParamID)
+	}
+
+	if p.opts.IndexLabelPrefix != "" && g.segment != "*" {
+		element[p.opts.IndexLabelPrefix+g.path] = json.RawMessage(strconv.Itoa(i))
+	}
+
+	if g.key != nil && n.parent != nil && isObject(n.parent.data) {
+		quoted, _ := json.Marshal(key) // nolint:errchkjson // strings always marshal
+		element[g.key.ParamID] = quoted
+		p.resolve(g.key.ParamID)
+	}
+
+	if len(element) == 0 {
+		return res, nil
+	}
+
+	return cartesianProduct(res, []RawMessageSet{element}), nil
 }
 
-func (e *UnmarshalError) Error() string {
-	return fmt.Sprintf("error: %s, param_id: %s", e.err, e.paramID)
+// length binds the length of n to the meta of a "#" group not following an
+// iterating segment: the number of characters of a string, of keys of an
+// object or of elements of an array. Numbers, booleans and null have no
+// length and leave the params unresolved.
+func (p *parser) length(n *node, g *group) ([]RawMessageSet, error) {
+	var length int
+
+	switch jsonType(n.data) {
+	case "string":
+		var s string
+		if err := p.codec().Unmarshal(n.data, &s); err != nil {
+			return nil, p.unmarshalError(err, g.meta[0].ParamID, n)
+		}
+
+		length = utf8.RuneCountInString(s)
+	case "object":
+		object, err := p.object(n)
+		if err != nil {
+			return nil, p.unmarshalError(err, g.meta[0].ParamID, n)
+		}
+
+		length = len(object)
+	case "array":
+		array, err := p.array(n)
+		if err != nil {
+			return nil, p.unmarshalError(err, g.meta[0].ParamID, n)
+		}
+
+		length = len(array)
+	default:
+		if err := p.checkRequired(g.meta, n); err != nil {
+			return nil, err
+		}
+
+		return []RawMessageSet{p.unresolved(g.meta, n)}, nil
+	}
+
+	return p.bindAll(n.child(json.RawMessage(strconv.Itoa(length)), g.segment), g.meta)
 }
 
-// nolint:wsl
-func ParseParams(data json.RawMessage, meta []MetaData) ([]RawMessageSet, error) {
-	if len(data) == 0 || len(meta) == 0 {
-		return []RawMessageSet{{}}, nil
+// keys binds the sorted keys of n as a JSON array of strings to the meta of a
+// "&keys" group. Other values than objects leave the params unresolved.
+func (p *parser) keys(n *node, g *group) ([]RawMessageSet, error) {
+	if !isObject(n.data) {
+		if err := p.checkRequired(g.meta, n); err != nil {
+			return nil, err
+		}
+
+		return []RawMessageSet{p.unresolved(g.meta, n)}, nil
+	}
+
+	object, err := p.object(n)
+	if err != nil {
+		return nil, p.unmarshalError(err, g.meta[0].ParamID, n)
 	}
 
-	if len(meta) == 1 && meta[0].Path == "" {
-		return []RawMessageSet{
-			{meta[0].ParamID: data},
-		}, nil
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
 	}
 
-	currentPathToNewMeta := make(map[string][]MetaData)
-	for i := 0; i < len(meta); i++ {
-		currentPath, restOfPath := splitPath(meta[i].Path)
-		currentPathToNewMeta[currentPath] = append(currentPathToNewMeta[currentPath],
-			MetaData{restOfPath, meta[i].ParamID})
+	sort.Strings(keys)
+
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return nil, err
 	}
 
+	return p.bindAll(n.child(data, g.segment), g.meta)
+}
+
+// bindAll binds the value of n to every entry of meta.
+func (p *parser) bindAll(n *node, meta []MetaData) ([]RawMessageSet, error) {
 	res := []RawMessageSet{{}}
-	for currentPath, newMeta := range currentPathToNewMeta {
-		currentRes, err := unmarshalNextLevel(data, newMeta, currentPath)
+	for _, m := range meta {
+		set, err := p.bind(n, m)
 		if err != nil {
 			return nil, err
 		}
 
-		res = cartesianProduct(res, currentRes)
+		res = cartesianProduct(res, []RawMessageSet{set})
 	}
 
 	return res, nil
 }
 
-// nolint:nestif,gocognit,cyclop
-func unmarshalNextLevel(data json.RawMessage, meta []MetaData, currentPath string) ([]RawMessageSet, error) {
-	if currentPath == "[]" {
-		metaBase, metaAll, metaIndex, metaCount := splitMeta(meta)
+// unresolved binds the defaults of the params in meta that can't be
+// resolved below n, the deepest node found, and the path of n if
+// Options.ProvenanceSuffix is set.
+func (p *parser) unresolved(meta []MetaData, n *node) RawMessageSet {
+	res := RawMessageSet{}
 
-		var resAll, resList []RawMessageSet
+	for _, m := range meta {
+		if m.Default != nil {
+			res[m.ParamID] = m.Default
+		}
 
-		if metaAll == nil {
-			resAll = []RawMessageSet{{}}
-		} else {
-			resAll = []RawMessageSet{{metaAll.ParamID: data}}
+		if p.opts.ProvenanceSuffix != "" {
+			res[m.ParamID+p.opts.ProvenanceSuffix] = json.RawMessage(strconv.Quote(n.path))
 		}
+	}
+
+	return res
+}
 
-		var sliceJSON []json.RawMessage
-		if err := json.Unmarshal(data, &sliceJSON); err != nil {
-			return nil, &UnmarshalError{err, meta[0].ParamID}
+// checkRequired returns a *MissingError for the first required param in
+// meta, or the first param in strict mode, which can't be resolved because n
+// is missing and has no default.
+func (p *parser) checkRequired(meta []MetaData, n *node) error {
+	for _, m := range meta {
+		if ((m.Required && !p.opts.DeferRequired) || p.opts.Strict) && m.Default == nil {
+			return &MissingError{m.ParamID, n.path}
 		}
+	}
 
-		if metaCount != nil {
-			resAll = cartesianProduct(resAll,
-				[]RawMessageSet{{metaCount.ParamID: json.RawMessage(strconv.Itoa(len(sliceJSON)))}})
+	return nil
+}
+
+func (p *parser) checkPopulated(res []RawMessageSet, meta []MetaData) ([]RawMessageSet, error) {
+	if p.opts.MinPopulatedParams <= 0 {
+		return res, nil
+	}
+
+	filtered := res[:0]
+
+	for i, set := range res {
+		populated := 0
+
+		seen := make(map[string]bool, len(meta))
+		for _, m := range meta {
+			if _, ok := set[m.ParamID]; ok && !seen[m.ParamID] {
+				seen[m.ParamID] = true
+				populated++
+			}
 		}
 
-		if len(sliceJSON) == 0 {
-			resList = []RawMessageSet{{}}
+		if populated >= p.opts.MinPopulatedParams {
+			filtered = append(filtered, set)
+			continue
 		}
 
-		if metaIndex != nil || len(metaBase) > 0 {
-			for i, JSON := range sliceJSON {
-				currentRes, err := ParseParams(JSON, metaBase)
-				if err != nil {
-					return nil, err
-				}
+		if !p.opts.DropUnderpopulated {
+			return nil, &UnderpopulatedError{p.populated + i, populated, p.opts.MinPopulatedParams}
+		}
+	}
 
-				var ixRes []RawMessageSet
-				if metaIndex == nil {
-					ixRes = []RawMessageSet{{}}
-				} else {
-					ixRes = []RawMessageSet{{metaIndex.ParamID: json.RawMessage(strconv.Itoa(i))}}
-				}
+	p.populated += len(res)
+
+	return filtered, nil
+}
 
-				currentRes = cartesianProduct(currentRes, ixRes)
+// checkRequiredSets returns a *ValidationError listing the sets of res
+// lacking a required param of meta, see Options.DeferRequired.
+func (p *parser) checkRequiredSets(res []RawMessageSet, meta []MetaData) error {
+	if !p.opts.DeferRequired {
+		return nil
+	}
+
+	var (
+		required []string
+		seen     = make(map[string]bool, len(meta))
+	)
+
+	for _, m := range meta {
+		if m.Required && !isTemplate(m.ParamID) && !seen[m.ParamID] {
+			seen[m.ParamID] = true
+			required = append(required, m.ParamID)
+		}
+	}
+
+	var incomplete []IncompleteSet
 
-				resList = append(resList, currentRes...)
+	for i, set := range res {
+		var missing []string
+
+		for _, paramID := range required {
+			if _, ok := set[paramID]; !ok {
+				missing = append(missing, paramID)
 			}
-		} else {
-			resList = []RawMessageSet{{}}
 		}
 
-		return cartesianProduct(resList, resAll), nil
+		if missing != nil {
+			incomplete = append(incomplete, IncompleteSet{p.finished + i, missing})
+		}
 	}
 
-	var rawMessage RawMessageSet
-	if err := json.Unmarshal(data, &rawMessage); err != nil {
-		return nil, &UnmarshalError{err, meta[0].ParamID}
+	if incomplete != nil {
+		return &ValidationError{incomplete}
 	}
 
-	value, ok := rawMessage[currentPath]
-	if !ok {
-		return []RawMessageSet{{}}, nil
+	return nil
+}
+
+func (p *parser) object(n *node) (RawMessageSet, error) {
+	if n.object != nil {
+		return n.object, nil
 	}
 
-	res, err := ParseParams(value, meta)
-	if err != nil {
+	var object RawMessageSet
+	if err := p.codec().Unmarshal(n.data, &object); err != nil {
+		return nil, err
+	}
+
+	if p.opts.DetectDuplicateKeys {
+		if key, ok := p.duplicateKey(n.data); ok {
+			return nil, &DuplicateKeyError{key}
+		}
+	}
+
+	if !p.opts.DisableMemoization {
+		n.object = object
+	}
+
+	return object, nil
+}
+
+func (p *parser) array(n *node) ([]json.RawMessage, error) {
+	if n.array != nil {
+		return n.array, nil
+	}
+
+	var array []json.RawMessage
+	if err := p.codec().Unmarshal(n.data, &array); err != nil {
+		return nil, err
+	}
+
+	if !p.opts.DisableMemoization {
+		n.array = array
+	}
+
+	return array, nil
+}
+
+// resolve records that the path of the meta entry paramID resolved.
+func (p *parser) resolve(paramID string) {
+	if p.resolved != nil {
+		p.resolved[paramID] = true
+	}
+}
+
+// collected returns the errors collected with Options.CollectErrors, if any.
+func (p *parser) collected() error {
+	if p.errs == nil || len(*p.errs) == 0 {
+		return nil
+	}
+
+	return &MultiError{*p.errs}
+}
+
+// canceled returns the error of the context of the parse once it is done.
+func (p *parser) canceled() error {
+	if p.ctx == nil {
+		return nil
+	}
+
+	return p.ctx.Err()
+}
+
+func (p *parser) codec() JSONCodec {
+	if p.opts.Codec == nil {
+		return StdCodec{}
+	}
+
+	return p.opts.Codec
+}
+
+func (p *parser) now() time.Time {
+	if p.opts.Clock == nil {
+		return time.Now()
+	}
+
+	return p.opts.Clock()
+}
+
+// bind validates the node value against m and returns a set holding it
+// under m.ParamID, along with the requested companion values.
+func (p *parser) bind(n *node, m MetaData) (RawMessageSet, error) {
+	if err := p.validateSchema(n.data, m); err != nil {
 		return nil, err
 	}
 
+	p.resolve(m.ParamID)
+
+	if p.explained != nil {
+		*p.explained = append(*p.explained, n.path)
+	}
+
+	if isTemplate(m.ParamID) {
+		paramID, err := p.expandTemplate(n, m.ParamID)
+		if err != nil {
+			return nil, err
+		}
+
+		m.ParamID = paramID
+	}
+
+	value := n.data
+
+	if m.Transform != nil {
+		transformed, err := m.Transform(value)
+		if err != nil {
+			return nil, &TransformError{m.ParamID, n.path, err}
+		}
+
+		value = transformed
+	}
+
+	res := RawMessageSet{m.ParamID: value}
+
+	if p.opts.ParentPathSuffix != "" && n.parent != nil {
+		res[m.ParamID+p.opts.ParentPathSuffix] = json.RawMessage(strconv.Quote(n.parent.path))
+	}
+
+	if p.opts.OffsetSuffix != "" {
+		if start, ok := n.offset(p.codec()); ok {
+			res[m.ParamID+p.opts.OffsetSuffix] = json.RawMessage(
+				"[" + strconv.Itoa(start) + "," + strconv.Itoa(start+len(n.data)) + "]")
+		}
+	}
+
+	if p.opts.EnclosingObjectSuffix != "" {
+		for parent := n.parent; parent != nil; parent = parent.parent {
+			if isObject(parent.data) {
+				res[m.ParamID+p.opts.EnclosingObjectSuffix] = parent.data
+				break
+			}
+		}
+	}
+
 	return res, nil
 }
 
-// nolint:gomnd
+// elementIndex maps the k-th iteration step to the index of the array
+// element visited at that step.
+func (p *parser) elementIndex(k, length int) int {
+	if p.opts.ReverseArrays {
+		return length - 1 - k
+	}
+
+	return k
+}
+
+func (p *parser) checkElementDepth(n *node) error {
+	if p.opts.MaxElementDepth <= 0 {
+		return nil
+	}
+
+	if depth := nestingDepth(n.data); depth > p.opts.MaxElementDepth {
+		return &ElementDepthError{n.path, depth, p.opts.MaxElementDepth}
+	}
+
+	return nil
+}
+
+// nestingDepth returns the maximum number of nested objects and arrays in
+// data: 0 for scalars, 1 for a flat object or array and so on.
+func nestingDepth(data json.RawMessage) int {
+	var depth, maxDepth int
+
+	inString, escaped := false, false
+
+	for _, c := range data {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch c {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+			if depth > maxDepth {
+				maxDepth = depth
+			}
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+
+	return maxDepth
+}
+
+func indexSegment(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+// isScalar reports whether data is neither a JSON object nor an array.
+func isScalar(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+
+	return len(data) > 0 && data[0] != '{' && data[0] != '['
+}
+
+// checkContainer returns a *ContainerError in strict mode if n doesn't hold
+// the JSON type expected by the segment resolved in it.
+func (p *parser) checkContainer(n *node, meta []MetaData, expected string) error {
+	if !p.opts.Strict {
+		return nil
+	}
+
+	if actual := jsonType(n.data); actual != expected {
+		return &ContainerError{meta[0].ParamID, n.path, expected, actual}
+	}
+
+	return nil
+}
+
+// isObject reports whether data is a JSON object.
+func isObject(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+
+	return len(data) > 0 && data[0] == '{'
+}
+
+// isArray reports whether data is a JSON array.
+func isArray(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+
+	return len(data) > 0 && data[0] == '['
+}
+
+// splitPath splits the first segment off path. Dots in a segment are escaped
+// with a backslash, as in `activities.43\.21`, or the segment is a quoted key,
+// as in `activities.["43.21"]`, see unescapeKey.
 func splitPath(path string) (currentPath, restOfPath string) {
-	res := strings.SplitN(path, ".", 2)
-	if len(res) == 1 {
-		return res[0], ""
+	// The value of a filter may hold dots.
+	if strings.HasPrefix(path, "[?") {
+		if i := strings.Index(path, "]"); i >= 0 && (i == len(path)-1 || path[i+1] == '.') {
+			return path[:i+1], strings.TrimPrefix(path[i+1:], ".")
+		}
+	}
+
+	if i := quotedKeyEnd(path); i > 0 {
+		return path[:i], strings.TrimPrefix(path[i:], ".")
+	}
+
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '\\':
+			i++
+		case '.':
+			return path[:i], path[i+1:]
+		}
+	}
+
+	return path, ""
+}
+
+// quotedKeyEnd returns the length of the `["key"]` segment path starts with,
+// or 0 if it doesn't start with one.
+func quotedKeyEnd(path string) int {
+	if !strings.HasPrefix(path, `["`) {
+		return 0
+	}
+
+	for i := 2; i < len(path); i++ {
+		switch path[i] {
+		case '\\':
+			i++
+		case '"':
+			if !strings.HasPrefix(path[i+1:], "]") || (i+2 < len(path) && path[i+2] != '.') {
+				return 0
+			}
+
+			return i + 2
+		}
+	}
+
+	return 0
+}
+
+// keySegment writes an object key as a path segment: plain when it parses
+// back as that key, else as a quoted `["key"]` segment.
+func keySegment(key string) string {
+	if key == "" || isArrayIndex(key) || strings.ContainsAny(key, `.\[]~$&*#@%?^`) {
+		quoted, _ := json.Marshal(key) // nolint:errchkjson // strings always marshal
+		return "[" + string(quoted) + "]"
+	}
+
+	return key
+}
+
+// unescapeKey returns the object key named by a key segment: the JSON string
+// of a `["key"]` segment, or the segment with backslash escapes removed.
+func unescapeKey(segment string) string {
+	if quotedKeyEnd(segment) == len(segment) && len(segment) > 0 {
+		var key string
+		if err := json.Unmarshal([]byte(segment[1:len(segment)-1]), &key); err == nil {
+			return key
+		}
+	}
+
+	if !strings.Contains(segment, "\\") {
+		return segment
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(segment); i++ {
+		if segment[i] == '\\' && i+1 < len(segment) {
+			i++
+		}
+
+		b.WriteByte(segment[i])
+	}
+
+	return b.String()
+}
+
+var ErrTooManyRows = errors.New("too many result sets")
+
+var ErrMaxDepthExceeded = errors.New("maximum traversal depth exceeded")
+
+// defaultMaxDepth bounds the traversal when Options.MaxDepth is zero.
+const defaultMaxDepth = 1000
+
+// checkDepth returns ErrMaxDepthExceeded if n lies deeper than
+// Options.MaxDepth.
+func (p *parser) checkDepth(n *node) error {
+	maxDepth := p.opts.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxDepth
+	}
+
+	if n.depth > maxDepth {
+		return ErrMaxDepthExceeded
+	}
+
+	return nil
+}
+
+// product is cartesianProduct failing with ErrTooManyRows when the result
+// would hold more than Options.MaxRows sets.
+func (p *parser) product(rawSets1, rawSets2 []RawMessageSet) ([]RawMessageSet, error) {
+	if p.opts.MaxRows > 0 && len(rawSets1)*len(rawSets2) > p.opts.MaxRows {
+		return nil, ErrTooManyRows
 	}
 
-	return res[0], res[1]
+	return cartesianProduct(rawSets1, rawSets2), nil
 }
 
+// cartesianProduct merges every set of rawSets1 with every set of rawSets2.
+// A side holding the single empty set is the identity, and the other side is
+// returned as is.
 func cartesianProduct(rawSets1, rawSets2 []RawMessageSet) []RawMessageSet {
+	if isIdentity(rawSets2) {
+		return rawSets1
+	}
+
+	if isIdentity(rawSets1) {
+		return rawSets2
+	}
+
 	res := make([]RawMessageSet, len(rawSets1)*len(rawSets2))
 
-	for i, set1 := range rawSets1 {
+	i := 0
+
+	for _, set1 := range rawSets1 {
 		for _, set2 := range rawSets2 {
-			newMap := RawMessageSet{}
+			newMap := make(RawMessageSet, len(set1)+len(set2))
 
 			for k, v := range set1 {
 				newMap[k] = v
@@ -158,8 +1602,21 @@ func cartesianProduct(rawSets1, rawSets2 []RawMessageSet) []RawMessageSet {
 	return res
 }
 
+func isIdentity(rawSets []RawMessageSet) bool {
+	return len(rawSets) == 1 && len(rawSets[0]) == 0
+}
+
+// splitMeta separates the tokens following "[]" from the per-element meta:
+// "" binds the whole array, "@" the index of each element, "%" the key of each
+// value iterated by "*", "#" the length, "#1" whether it holds a single
+// element and "$" its last element. All but "@" and "%" are bound once per
+// array and combined with every element row.
+//
+// A key spelling a token is escaped with a backslash, as in `\@` or `\#`, or
+// quoted, as in `["@"]` or `[""]`, and looked up as a key by the base meta.
+//
 // nolint:revive
-func splitMeta(meta []MetaData) (metaBase []MetaData, metaAll, metaIndex, metaCount *MetaData) {
+func splitMeta(meta []MetaData) (metaBase []MetaData, metaAll, metaIndex, metaKey, metaCount, metaSingleton, metaLast *MetaData) {
 	metaBase = []MetaData{}
 
 	for _, v := range meta {
@@ -167,8 +1624,14 @@ func splitMeta(meta []MetaData) (metaBase []MetaData, metaAll, metaIndex, metaCo
 		switch v.Path {
 		case "@":
 			metaIndex = &v
+		case "%":
+			metaKey = &v
 		case "#":
 			metaCount = &v
+		case "#1":
+			metaSingleton = &v
+		case "$":
+			metaLast = &v
 		case "":
 			metaAll = &v
 		default:
@@ -176,5 +1639,5 @@ func splitMeta(meta []MetaData) (metaBase []MetaData, metaAll, metaIndex, metaCo
 		}
 	}
 
-	return metaBase, metaAll, metaIndex, metaCount
+	return metaBase, metaAll, metaIndex, metaKey, metaCount, metaSingleton, metaLast
 }
@@ -1,9 +1,15 @@
 package jparser_test
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/egelis/jparser"
 )
@@ -13,6 +19,16 @@ type args struct {
 	meta []jparser.MetaData
 }
 
+// testCodecs runs the common test tables against every JSONCodec, nil
+// standing for the default one.
+var testCodecs = []struct {
+	name  string
+	codec jparser.JSONCodec
+}{
+	{name: "encoding/json"},
+	{name: "counting codec", codec: &countingCodec{}},
+}
+
 func TestParseParamsSuccess(t *testing.T) {
 	testTable := []struct {
 		name        string
@@ -24,10 +40,10 @@ func TestParseParamsSuccess(t *testing.T) {
 			args: args{
 				data: oneElementInArrayJSON,
 				meta: []jparser.MetaData{
-					{"[].UL.branches.[].kpp", "kpp"},
-					{"[].inn", "inn"},
-					{"[].UL.branches.[].date", "date"},
-					{"[].UL.legalAddress.parsedAddressRF.non-existing", "non-existing"},
+					{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+					{Path: "[].inn", ParamID: "inn"},
+					{Path: "[].UL.branches.[].date", ParamID: "date"},
+					{Path: "[].UL.legalAddress.parsedAddressRF.non-existing", ParamID: "non-existing"},
 				},
 			},
 			expectedRes: []jparser.RawMessageSet{
@@ -63,8 +79,8 @@ func TestParseParamsSuccess(t *testing.T) {
 			args: args{
 				data: multipleElementsInArrayJSON,
 				meta: []jparser.MetaData{
-					{"[].UL.branches.[].date", "date1"},
-					{"[].IP.status.date", "date2"},
+					{Path: "[].UL.branches.[].date", ParamID: "date1"},
+					{Path: "[].IP.status.date", ParamID: "date2"},
 				},
 			},
 			expectedRes: []jparser.RawMessageSet{
@@ -90,8 +106,8 @@ func TestParseParamsSuccess(t *testing.T) {
 			args: args{
 				data: oneObjectInJSON,
 				meta: []jparser.MetaData{
-					{"inn", "inn"},
-					{"IP.status.statusString", "status"},
+					{Path: "inn", ParamID: "inn"},
+					{Path: "IP.status.statusString", ParamID: "status"},
 				},
 			},
 			expectedRes: []jparser.RawMessageSet{
@@ -106,7 +122,7 @@ func TestParseParamsSuccess(t *testing.T) {
 			args: args{
 				data: json.RawMessage(`[]`),
 				meta: []jparser.MetaData{
-					{"[].UL.heads", "heads"},
+					{Path: "[].UL.heads", ParamID: "heads"},
 				},
 			},
 			expectedRes: []jparser.RawMessageSet{{}},
@@ -116,8 +132,8 @@ func TestParseParamsSuccess(t *testing.T) {
 			args: args{
 				data: oneElementInArrayJSON,
 				meta: []jparser.MetaData{
-					{"[].UL.branches.[].@", "branches_index"},
-					{"[].UL.branches.[].#", "branches_count"},
+					{Path: "[].UL.branches.[].@", ParamID: "branches_index"},
+					{Path: "[].UL.branches.[].#", ParamID: "branches_count"},
 				},
 			},
 			expectedRes: []jparser.RawMessageSet{
@@ -148,7 +164,7 @@ func TestParseParamsSuccess(t *testing.T) {
 			args: args{
 				data: oneElementInArrayJSON,
 				meta: []jparser.MetaData{
-					{"[].UL.history.kpps.[]", "kpps"},
+					{Path: "[].UL.history.kpps.[]", ParamID: "kpps"},
 				},
 			},
 			expectedRes: []jparser.RawMessageSet{
@@ -168,21 +184,23 @@ func TestParseParamsSuccess(t *testing.T) {
 		},
 	}
 
-	for _, test := range testTable {
-		t.Run(test.name, func(t *testing.T) {
-			result, err := jparser.ParseParams(test.args.data, test.args.meta)
+	for _, codec := range testCodecs {
+		for _, test := range testTable {
+			t.Run(codec.name+"/"+test.name, func(t *testing.T) {
+				result, err := jparser.ParseParamsWithOptions(test.args.data, test.args.meta, jparser.Options{Codec: codec.codec})
 
-			if err != nil {
-				t.Errorf("ParseParams() got error = \"%v\", expected nil", err)
-				return
-			}
+				if err != nil {
+					t.Errorf("ParseParams() got error = \"%v\", expected nil", err)
+					return
+				}
 
-			if !reflect.DeepEqual(result, test.expectedRes) {
-				got, _ := json.MarshalIndent(result, "", "  ")
-				expected, _ := json.MarshalIndent(test.expectedRes, "", "  ")
-				t.Errorf("ParseParams() got result = %s\nexpectedRes = %s", got, expected)
-			}
-		})
+				if !reflect.DeepEqual(result, test.expectedRes) {
+					got, _ := json.MarshalIndent(result, "", "  ")
+					expected, _ := json.MarshalIndent(test.expectedRes, "", "  ")
+					t.Errorf("ParseParams() got result = %s\nexpectedRes = %s", got, expected)
+				}
+			})
+		}
 	}
 }
 
@@ -196,7 +214,7 @@ func TestParseParamsErrors(t *testing.T) {
 			args: args{
 				data: brokenJSON,
 				meta: []jparser.MetaData{
-					{"[].inn", "inn"},
+					{Path: "[].inn", ParamID: "inn"},
 				},
 			},
 		},
@@ -205,8 +223,8 @@ func TestParseParamsErrors(t *testing.T) {
 			args: args{
 				data: oneElementInArrayJSON,
 				meta: []jparser.MetaData{
-					{"[].UL.branches.[].kpp", "kpp_param"},
-					{"[].UL.branches.wrong_path", "wrong_path_param"},
+					{Path: "[].UL.branches.[].kpp", ParamID: "kpp_param"},
+					{Path: "[].UL.branches.wrong_path", ParamID: "wrong_path_param"},
 				},
 			},
 		},
@@ -215,8 +233,249 @@ func TestParseParamsErrors(t *testing.T) {
 			args: args{
 				data: oneElementInArrayJSON,
 				meta: []jparser.MetaData{
-					{"[].UL.branches.[].kpp", "kpp"},
-					{"[].UL.[].wrong_path", "wrong_path"},
+					{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+					{Path: "[].UL.[].wrong_path", ParamID: "wrong_path"},
+				},
+			},
+		},
+	}
+
+	for _, codec := range testCodecs {
+		for _, test := range testTable {
+			t.Run(codec.name+"/"+test.name, func(t *testing.T) {
+				result, err := jparser.ParseParamsWithOptions(test.args.data, test.args.meta, jparser.Options{Codec: codec.codec})
+
+				if err == nil {
+					t.Errorf("ParseParams() got error = nil, expected error")
+					return
+				}
+
+				if result != nil {
+					got, _ := json.MarshalIndent(result, "", "  ")
+					t.Errorf("ParseParams() got result = %s, expectedRes = nil", got)
+				}
+			})
+		}
+	}
+}
+
+func TestParseParamsClock(t *testing.T) {
+	clock := func() time.Time {
+		return time.Date(2022, 9, 7, 12, 30, 0, 0, time.UTC)
+	}
+
+	result, err := jparser.ParseParamsWithOptions(oneObjectInJSON, []jparser.MetaData{
+		{Path: "inn", ParamID: "inn"},
+		{Path: "&now", ParamID: "extracted_at"},
+	}, jparser.Options{Clock: clock})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		{
+			"inn":          json.RawMessage(`"772473497153"`),
+			"extracted_at": json.RawMessage(`"2022-09-07T12:30:00Z"`),
+		},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		got, _ := json.MarshalIndent(result, "", "  ")
+		expected, _ := json.MarshalIndent(expectedRes, "", "  ")
+		t.Errorf("ParseParamsWithOptions() got result = %s\nexpectedRes = %s", got, expected)
+	}
+}
+
+func TestParseParamsParentPath(t *testing.T) {
+	result, err := jparser.ParseParamsWithOptions(oneElementInArrayJSON, []jparser.MetaData{
+		{Path: "[].UL.legalAddress.parsedAddressRFFias.buildings.[].topoValue", ParamID: "building"},
+		{Path: "[].inn", ParamID: "inn"},
+	}, jparser.Options{ParentPathSuffix: "_parent"})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		{
+			"building":        json.RawMessage(`"19а"`),
+			"building_parent": json.RawMessage(`"[0].UL.legalAddress.parsedAddressRFFias.buildings.[0]"`),
+			"inn":             json.RawMessage(`"6663003127"`),
+			"inn_parent":      json.RawMessage(`"[0]"`),
+		},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		got, _ := json.MarshalIndent(result, "", "  ")
+		expected, _ := json.MarshalIndent(expectedRes, "", "  ")
+		t.Errorf("ParseParamsWithOptions() got result = %s\nexpectedRes = %s", got, expected)
+	}
+}
+
+func TestParseParamsEnclosingObject(t *testing.T) {
+	data := json.RawMessage(`{"branches": [
+		{"kpp": "771543001", "parsedAddressRF": {"regionCode": "77"}},
+		{"kpp": "780243001", "parsedAddressRF": {"regionCode": "78"}}
+	]}`)
+
+	result, err := jparser.ParseParamsWithOptions(data, []jparser.MetaData{
+		{Path: "branches.[].kpp", ParamID: "kpp"},
+	}, jparser.Options{EnclosingObjectSuffix: "_branch"})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		{
+			"kpp":        json.RawMessage(`"771543001"`),
+			"kpp_branch": json.RawMessage(`{"kpp": "771543001", "parsedAddressRF": {"regionCode": "77"}}`),
+		},
+		{
+			"kpp":        json.RawMessage(`"780243001"`),
+			"kpp_branch": json.RawMessage(`{"kpp": "780243001", "parsedAddressRF": {"regionCode": "78"}}`),
+		},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		got, _ := json.MarshalIndent(result, "", "  ")
+		expected, _ := json.MarshalIndent(expectedRes, "", "  ")
+		t.Errorf("ParseParamsWithOptions() got result = %s\nexpectedRes = %s", got, expected)
+	}
+}
+
+func TestParseParamsReverseArrays(t *testing.T) {
+	result, err := jparser.ParseParamsWithOptions(oneElementInArrayJSON, []jparser.MetaData{
+		{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+		{Path: "[].UL.branches.[].@", ParamID: "index"},
+	}, jparser.Options{ReverseArrays: true})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		{"kpp": json.RawMessage(`"745343002"`), "index": json.RawMessage(`4`)},
+		{"kpp": json.RawMessage(`"590443001"`), "index": json.RawMessage(`3`)},
+		{"kpp": json.RawMessage(`"780243001"`), "index": json.RawMessage(`2`)},
+		{"kpp": json.RawMessage(`"771543002"`), "index": json.RawMessage(`1`)},
+		{"kpp": json.RawMessage(`"771543001"`), "index": json.RawMessage(`0`)},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		got, _ := json.MarshalIndent(result, "", "  ")
+		expected, _ := json.MarshalIndent(expectedRes, "", "  ")
+		t.Errorf("ParseParamsWithOptions() got result = %s\nexpectedRes = %s", got, expected)
+	}
+}
+
+func TestParseParamsMaxElementDepth(t *testing.T) {
+	data := json.RawMessage(`[{"a": 1}, {"a": {"b": {"c": [1]}}}]`)
+	meta := []jparser.MetaData{{Path: "[].a", ParamID: "a"}}
+
+	if _, err := jparser.ParseParamsWithOptions(data, meta, jparser.Options{MaxElementDepth: 4}); err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	result, err := jparser.ParseParamsWithOptions(data, meta, jparser.Options{MaxElementDepth: 3})
+
+	var depthErr *jparser.ElementDepthError
+	if !errors.As(err, &depthErr) {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected *ElementDepthError", err)
+	}
+
+	if depthErr.Path != "[1]" || depthErr.Depth != 4 {
+		t.Errorf("ParseParamsWithOptions() got path = %q, depth = %d, expected \"[1]\", 4", depthErr.Path, depthErr.Depth)
+	}
+
+	if result != nil {
+		t.Errorf("ParseParamsWithOptions() got result = %v, expected nil", result)
+	}
+}
+
+func TestParseParamsEmptyMetaPerElement(t *testing.T) {
+	testTable := []struct {
+		name        string
+		data        json.RawMessage
+		meta        []jparser.MetaData
+		opts        jparser.Options
+		expectedRes []jparser.RawMessageSet
+	}{
+		{
+			name:        "Default",
+			data:        multipleElementsInArrayJSON,
+			expectedRes: []jparser.RawMessageSet{{}},
+		},
+		{
+			name:        "One set per element",
+			data:        multipleElementsInArrayJSON,
+			opts:        jparser.Options{EmptyMetaPerElement: true},
+			expectedRes: []jparser.RawMessageSet{{}, {}, {}},
+		},
+		{
+			name:        "Object is not expanded",
+			data:        oneObjectInJSON,
+			opts:        jparser.Options{EmptyMetaPerElement: true},
+			expectedRes: []jparser.RawMessageSet{{}},
+		},
+		{
+			name:        "Empty array",
+			data:        json.RawMessage(`[]`),
+			opts:        jparser.Options{EmptyMetaPerElement: true},
+			expectedRes: []jparser.RawMessageSet{{}},
+		},
+		{
+			name: "Nested arrays are not expanded",
+			data: json.RawMessage(`[[1, 2], [3]]`),
+			meta: []jparser.MetaData{{Path: "[].@", ParamID: "index"}},
+			opts: jparser.Options{EmptyMetaPerElement: true},
+			expectedRes: []jparser.RawMessageSet{
+				{"index": json.RawMessage(`0`)},
+				{"index": json.RawMessage(`1`)},
+			},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := jparser.ParseParamsWithOptions(test.data, test.meta, test.opts)
+			if err != nil {
+				t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+				return
+			}
+
+			if !reflect.DeepEqual(result, test.expectedRes) {
+				t.Errorf("ParseParamsWithOptions() got result = %v, expectedRes = %v", result, test.expectedRes)
+			}
+		})
+	}
+}
+
+func TestParseParamsAutoRoot(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "inn", ParamID: "inn"},
+		{Path: "ogrn", ParamID: "ogrn"},
+	}
+
+	testTable := []struct {
+		name        string
+		data        json.RawMessage
+		expectedRes []jparser.RawMessageSet
+	}{
+		{
+			name: "Object root",
+			data: oneObjectInJSON,
+			expectedRes: []jparser.RawMessageSet{
+				{
+					"inn":  json.RawMessage(`"772473497153"`),
+					"ogrn": json.RawMessage(`"318774600372150"`),
+				},
+			},
+		},
+		{
+			name: "Array root",
+			data: oneElementInArrayJSON,
+			expectedRes: []jparser.RawMessageSet{
+				{
+					"inn":  json.RawMessage(`"6663003127"`),
+					"ogrn": json.RawMessage(`"1026605606620"`),
 				},
 			},
 		},
@@ -224,19 +483,1789 @@ func TestParseParamsErrors(t *testing.T) {
 
 	for _, test := range testTable {
 		t.Run(test.name, func(t *testing.T) {
-			result, err := jparser.ParseParams(test.args.data, test.args.meta)
+			result, err := jparser.ParseParamsWithOptions(test.data, meta, jparser.Options{AutoRoot: true})
+			if err != nil {
+				t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+				return
+			}
+
+			if !reflect.DeepEqual(result, test.expectedRes) {
+				t.Errorf("ParseParamsWithOptions() got result = %v, expectedRes = %v", result, test.expectedRes)
+			}
+		})
+	}
+}
+
+func TestParseParamsProvenance(t *testing.T) {
+	meta := []jparser.MetaData{{Path: "contactPhones.count", ParamID: "phones"}}
+
+	testTable := []struct {
+		name        string
+		data        json.RawMessage
+		expectedRes []jparser.RawMessageSet
+	}{
+		{
+			name: "Populated parent",
+			data: json.RawMessage(`{"contactPhones": {"count": 77}}`),
+			expectedRes: []jparser.RawMessageSet{
+				{"phones": json.RawMessage(`77`)},
+			},
+		},
+		{
+			name: "Empty parent",
+			data: oneObjectInJSON,
+			expectedRes: []jparser.RawMessageSet{
+				{"phones_found": json.RawMessage(`"contactPhones"`)},
+			},
+		},
+		{
+			name: "Missing parent",
+			data: json.RawMessage(`{"inn": "772473497153"}`),
+			expectedRes: []jparser.RawMessageSet{
+				{"phones_found": json.RawMessage(`""`)},
+			},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := jparser.ParseParamsWithOptions(test.data, meta, jparser.Options{ProvenanceSuffix: "_found"})
+			if err != nil {
+				t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+				return
+			}
+
+			if !reflect.DeepEqual(result, test.expectedRes) {
+				t.Errorf("ParseParamsWithOptions() got result = %v, expectedRes = %v", result, test.expectedRes)
+			}
+		})
+	}
+}
+
+func TestParseParamsSingleton(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "[].phones.[].#", ParamID: "count"},
+		{Path: "[].phones.[].#1", ParamID: "singleton"},
+	}
+
+	data := json.RawMessage(`[
+		{"phones": ["+7 343 228-12-34"]},
+		{"phones": ["+7 343 228-12-34", "+7 495 123-45-67"]},
+		{"phones": []}
+	]`)
+
+	result, err := jparser.ParseParams(data, meta)
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	expected := []jparser.RawMessageSet{
+		{"count": json.RawMessage(`1`), "singleton": json.RawMessage(`true`)},
+		{"count": json.RawMessage(`2`), "singleton": json.RawMessage(`false`)},
+		{"count": json.RawMessage(`0`), "singleton": json.RawMessage(`false`)},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("ParseParams() got result = %v, expected %v", result, expected)
+	}
+}
+
+func TestParseParamsMixedArrays(t *testing.T) {
+	data := json.RawMessage(`{"phones": [{"number": "+7 343 228-12-34"}, "+7 495 123-45-67", null]}`)
+
+	meta := []jparser.MetaData{
+		{Path: "phones.[].number", ParamID: "number"},
+		{Path: "phones.[].@", ParamID: "index"},
+	}
+
+	testTable := []struct {
+		name        string
+		opts        jparser.Options
+		expectedRes []jparser.RawMessageSet
+		expectedErr bool
+	}{
+		{
+			name:        "Error",
+			expectedErr: true,
+		},
+		{
+			name: "Skip",
+			opts: jparser.Options{MixedArrays: jparser.MixedSkip},
+			expectedRes: []jparser.RawMessageSet{
+				{"number": json.RawMessage(`"+7 343 228-12-34"`), "index": json.RawMessage(`0`)},
+			},
+		},
+		{
+			name: "As value",
+			opts: jparser.Options{MixedArrays: jparser.MixedAsValue, MixedValueParamID: "raw"},
+			expectedRes: []jparser.RawMessageSet{
+				{"number": json.RawMessage(`"+7 343 228-12-34"`), "index": json.RawMessage(`0`)},
+				{"raw": json.RawMessage(`"+7 495 123-45-67"`), "index": json.RawMessage(`1`)},
+				{"raw": json.RawMessage(`null`), "index": json.RawMessage(`2`)},
+			},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := jparser.ParseParamsWithOptions(data, meta, test.opts)
+			if test.expectedErr {
+				var unmarshalErr *jparser.UnmarshalError
+				if !errors.As(err, &unmarshalErr) {
+					t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected *UnmarshalError", err)
+				}
+
+				return
+			}
 
-			if err == nil {
-				t.Errorf("ParseParams() got error = nil, expected error")
+			if err != nil {
+				t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
 				return
 			}
 
-			if result != nil {
-				got, _ := json.MarshalIndent(result, "", "  ")
-				t.Errorf("ParseParams() got result = %s, expectedRes = nil", got)
+			if !reflect.DeepEqual(result, test.expectedRes) {
+				t.Errorf("ParseParamsWithOptions() got result = %v, expectedRes = %v", result, test.expectedRes)
+			}
+		})
+	}
+}
+
+func TestParseParamsStrict(t *testing.T) {
+	testTable := []struct {
+		name        string
+		meta        []jparser.MetaData
+		expectedErr error
+	}{
+		{
+			name: "Missing key",
+			meta: []jparser.MetaData{
+				{Path: "[].inn", ParamID: "inn"},
+				{Path: "[].UL.legalAddress.parsedAddressRF.non-existing", ParamID: "non-existing"},
+			},
+			expectedErr: &jparser.MissingError{
+				ParamID: "non-existing",
+				Path:    "[0].UL.legalAddress.parsedAddressRF.non-existing",
+			},
+		},
+		{
+			name: "Key in a string",
+			meta: []jparser.MetaData{
+				{Path: "[].UL.kpp.code", ParamID: "code"},
+			},
+			expectedErr: &jparser.ContainerError{ParamID: "code", Path: "[0].UL.kpp", Expected: "object", Actual: "string"},
+		},
+		{
+			name: "Iterating an object",
+			meta: []jparser.MetaData{
+				{Path: "[].UL.[].kpp", ParamID: "kpp"},
+			},
+			expectedErr: &jparser.ContainerError{ParamID: "kpp", Path: "[0].UL", Expected: "array", Actual: "object"},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := jparser.ParseParamsWithOptions(oneElementInArrayJSON, test.meta, jparser.Options{Strict: true})
+			if !reflect.DeepEqual(err, test.expectedErr) {
+				t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected \"%v\"", err, test.expectedErr)
 			}
 		})
 	}
+
+	if _, err := jparser.ParseParams(oneElementInArrayJSON, testTable[0].meta); err != nil {
+		t.Errorf("ParseParams() got error = \"%v\", expected nil without Strict", err)
+	}
+}
+
+func TestParseParamsDefault(t *testing.T) {
+	testTable := []struct {
+		name        string
+		data        json.RawMessage
+		meta        []jparser.MetaData
+		expectedRes []jparser.RawMessageSet
+	}{
+		{
+			name: "Missing scalar appears once per row",
+			data: json.RawMessage(`{"inn": "6663003127", "branches": [{"kpp": "771543001"}, {"kpp": "780243001"}]}`),
+			meta: []jparser.MetaData{
+				{Path: "branches.[].kpp", ParamID: "kpp"},
+				{Path: "ogrn", ParamID: "ogrn", Default: json.RawMessage(`null`)},
+			},
+			expectedRes: []jparser.RawMessageSet{
+				{"kpp": json.RawMessage(`"771543001"`), "ogrn": json.RawMessage(`null`)},
+				{"kpp": json.RawMessage(`"780243001"`), "ogrn": json.RawMessage(`null`)},
+			},
+		},
+		{
+			name: "Missing in an array element",
+			data: json.RawMessage(`{"branches": [{"kpp": "771543001"}, {}]}`),
+			meta: []jparser.MetaData{
+				{Path: "branches.[].kpp", ParamID: "kpp", Default: json.RawMessage(`"000000000"`)},
+			},
+			expectedRes: []jparser.RawMessageSet{
+				{"kpp": json.RawMessage(`"771543001"`)},
+				{"kpp": json.RawMessage(`"000000000"`)},
+			},
+		},
+		{
+			name: "Empty array",
+			data: json.RawMessage(`{"branches": []}`),
+			meta: []jparser.MetaData{
+				{Path: "branches.[].kpp", ParamID: "kpp", Default: json.RawMessage(`"000000000"`)},
+			},
+			expectedRes: []jparser.RawMessageSet{
+				{"kpp": json.RawMessage(`"000000000"`)},
+			},
+		},
+		{
+			name: "Satisfies Required",
+			data: json.RawMessage(`{}`),
+			meta: []jparser.MetaData{
+				{Path: "inn", ParamID: "inn", Required: true, Default: json.RawMessage(`""`)},
+			},
+			expectedRes: []jparser.RawMessageSet{
+				{"inn": json.RawMessage(`""`)},
+			},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := jparser.ParseParams(test.data, test.meta)
+			if err != nil {
+				t.Errorf("ParseParams() got error = \"%v\", expected nil", err)
+				return
+			}
+
+			if !reflect.DeepEqual(result, test.expectedRes) {
+				t.Errorf("ParseParams() got result = %v, expectedRes = %v", result, test.expectedRes)
+			}
+		})
+	}
+}
+
+func TestParseParamsIndex(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "[].UL.branches.[0].kpp", ParamID: "first_kpp"},
+		{Path: "[].UL.branches.[2].kpp", ParamID: "third_kpp"},
+		{Path: "[].UL.branches.[9].kpp", ParamID: "out_of_range"},
+		{Path: "[].UL.branches.[].parsedAddressRF.regionCode", ParamID: "region"},
+	}
+
+	result, err := jparser.ParseParams(oneElementInArrayJSON, meta)
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := make([]jparser.RawMessageSet, 0, 5)
+	for _, region := range []string{`"77"`, `"77"`, `"78"`, `"59"`, `"74"`} {
+		expectedRes = append(expectedRes, jparser.RawMessageSet{
+			"first_kpp": json.RawMessage(`"771543001"`),
+			"third_kpp": json.RawMessage(`"780243001"`),
+			"region":    json.RawMessage(region),
+		})
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParams() got result = %v, expectedRes = %v", result, expectedRes)
+	}
+
+	_, err = jparser.ParseParamsWithOptions(oneElementInArrayJSON, meta, jparser.Options{Strict: true})
+
+	expectedErr := &jparser.MissingError{ParamID: "out_of_range", Path: "[0].UL.branches.[9]"}
+	if !reflect.DeepEqual(err, expectedErr) {
+		t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected \"%v\"", err, expectedErr)
+	}
+}
+
+func TestParseParamsNegativeIndex(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "[].UL.branches.[-1].kpp", ParamID: "last_kpp"},
+		{Path: "[].UL.branches.[-2].kpp", ParamID: "penultimate_kpp"},
+		{Path: "[].UL.branches.[-6].kpp", ParamID: "underflow"},
+	}
+
+	testTable := []struct {
+		name        string
+		data        json.RawMessage
+		expectedRes []jparser.RawMessageSet
+	}{
+		{
+			name: "Five branches",
+			data: oneElementInArrayJSON,
+			expectedRes: []jparser.RawMessageSet{
+				{
+					"last_kpp":        json.RawMessage(`"745343002"`),
+					"penultimate_kpp": json.RawMessage(`"590443001"`),
+				},
+			},
+		},
+		{
+			name:        "Empty array",
+			data:        json.RawMessage(`[{"UL": {"branches": []}}]`),
+			expectedRes: []jparser.RawMessageSet{{}},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := jparser.ParseParams(test.data, meta)
+			if err != nil {
+				t.Errorf("ParseParams() got error = \"%v\", expected nil", err)
+				return
+			}
+
+			if !reflect.DeepEqual(result, test.expectedRes) {
+				t.Errorf("ParseParams() got result = %v, expectedRes = %v", result, test.expectedRes)
+			}
+		})
+	}
+}
+
+func TestParseParamsSlice(t *testing.T) {
+	all, err := jparser.ParseParams(oneElementInArrayJSON, []jparser.MetaData{
+		{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+		{Path: "[].UL.branches.[].@", ParamID: "index"},
+	})
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	testTable := []struct {
+		window      string
+		expectedRes []jparser.RawMessageSet
+	}{
+		{window: "[1:3]", expectedRes: all[1:3]},
+		{window: "[2:]", expectedRes: all[2:]},
+		{window: "[:3]", expectedRes: all[:3]},
+		{window: "[3:10]", expectedRes: all[3:]},
+		{window: "[-2:]", expectedRes: all[3:]},
+		{window: "[4:2]", expectedRes: []jparser.RawMessageSet{{}}},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.window, func(t *testing.T) {
+			result, err := jparser.ParseParams(oneElementInArrayJSON, []jparser.MetaData{
+				{Path: "[].UL.branches." + test.window + ".kpp", ParamID: "kpp"},
+				{Path: "[].UL.branches." + test.window + ".@", ParamID: "index"},
+			})
+			if err != nil {
+				t.Errorf("ParseParams() got error = \"%v\", expected nil", err)
+				return
+			}
+
+			if !reflect.DeepEqual(result, test.expectedRes) {
+				t.Errorf("ParseParams() got result = %v, expectedRes = %v", result, test.expectedRes)
+			}
+		})
+	}
+}
+
+func TestParseParamsLast(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "[].UL.branches.[].$", ParamID: "last_branch"},
+		{Path: "[].UL.branches.[].#", ParamID: "count"},
+	}
+
+	data := json.RawMessage(`[
+		{"UL": {"branches": [{"kpp": "771543001"}, {"kpp": "780243001"}]}},
+		{"UL": {"branches": []}}
+	]`)
+
+	result, err := jparser.ParseParams(data, meta)
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		{"last_branch": json.RawMessage(`{"kpp": "780243001"}`), "count": json.RawMessage(`2`)},
+		{"count": json.RawMessage(`0`)},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParams() got result = %v, expectedRes = %v", result, expectedRes)
+	}
+
+	result, err = jparser.ParseParams(oneElementInArrayJSON, append(meta,
+		jparser.MetaData{Path: "[].UL.branches.[].@", ParamID: "index"}))
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	if len(result) != 5 {
+		t.Fatalf("ParseParams() got %d sets, expected one per branch", len(result))
+	}
+
+	for i, set := range result {
+		if string(set["index"]) != strconv.Itoa(i) || string(set["count"]) != "5" {
+			t.Errorf("ParseParams() got set %d = %v, expected index %d and count 5", i, set, i)
+		}
+
+		if !reflect.DeepEqual(set["last_branch"], result[0]["last_branch"]) {
+			t.Errorf("ParseParams() got set %d with a different last branch", i)
+		}
+	}
+
+	_, err = jparser.ParseParamsWithOptions(data, meta, jparser.Options{Strict: true})
+
+	expectedErr := &jparser.MissingError{ParamID: "last_branch", Path: "[1].UL.branches.[]"}
+	if !reflect.DeepEqual(err, expectedErr) {
+		t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected \"%v\"", err, expectedErr)
+	}
+}
+
+func TestParseParamsWildcard(t *testing.T) {
+	data := json.RawMessage(`{"inn": "6663003127", "contactPhones": {
+		"77": {"count": 2},
+		"66": {"count": 5},
+		"78": {}
+	}}`)
+
+	result, err := jparser.ParseParams(data, []jparser.MetaData{
+		{Path: "inn", ParamID: "inn"},
+		{Path: "contactPhones.*.count", ParamID: "count"},
+		{Path: "contactPhones.*.@", ParamID: "position"},
+		{Path: "contactPhones.*.#", ParamID: "regions"},
+	})
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	inn, regions := json.RawMessage(`"6663003127"`), json.RawMessage(`3`)
+	expectedRes := []jparser.RawMessageSet{
+		{"inn": inn, "count": json.RawMessage(`5`), "position": json.RawMessage(`0`), "regions": regions},
+		{"inn": inn, "count": json.RawMessage(`2`), "position": json.RawMessage(`1`), "regions": regions},
+		{"inn": inn, "position": json.RawMessage(`2`), "regions": regions},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParams() got result = %v, expectedRes = %v", result, expectedRes)
+	}
+
+	result, err = jparser.ParseParams(oneObjectInJSON, []jparser.MetaData{
+		{Path: "IP.*.statusString", ParamID: "status"},
+	})
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes = []jparser.RawMessageSet{{"status": json.RawMessage(`"Действующее"`)}}
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParams() got result = %v, expectedRes = %v", result, expectedRes)
+	}
+}
+
+func TestParseParamsWildcardKey(t *testing.T) {
+	data := json.RawMessage(`{"contactPhones": {"77": {"count": 2}, "66 \"обл\"": {"count": 5}}}`)
+
+	result, err := jparser.ParseParams(data, []jparser.MetaData{
+		{Path: "contactPhones.*.%", ParamID: "region_key"},
+		{Path: "contactPhones.*.count", ParamID: "count"},
+	})
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		{"region_key": json.RawMessage(`"66 \"обл\""`), "count": json.RawMessage(`5`)},
+		{"region_key": json.RawMessage(`"77"`), "count": json.RawMessage(`2`)},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParams() got result = %v, expectedRes = %v", result, expectedRes)
+	}
+
+	for _, set := range result {
+		var key string
+		if err := json.Unmarshal(set["region_key"], &key); err != nil {
+			t.Errorf("region_key %s is not a JSON string: %v", set["region_key"], err)
+		}
+	}
+}
+
+func TestParseParamsEscapedKey(t *testing.T) {
+	data := json.RawMessage(`{"activities": {"43": {"21": "нет"}, "43.21": "Производство электромонтажных работ",
+		"43.22": "Производство санитарно-технических работ"}}`)
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "backslash", path: `activities.43\.21`},
+		{name: "quoted key", path: `activities.["43.21"]`},
+		{name: "quoted key at root", path: `["activities"].["43.21"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := jparser.ParseParams(data, []jparser.MetaData{
+				{Path: tt.path, ParamID: "okved", Required: true},
+				{Path: `activities.["43.22"]`, ParamID: "okved_extra"},
+			})
+			if err != nil {
+				t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+			}
+
+			expectedRes := []jparser.RawMessageSet{{
+				"okved":       json.RawMessage(`"Производство электромонтажных работ"`),
+				"okved_extra": json.RawMessage(`"Производство санитарно-технических работ"`),
+			}}
+
+			if !reflect.DeepEqual(result, expectedRes) {
+				t.Errorf("ParseParams() got result = %v, expectedRes = %v", result, expectedRes)
+			}
+		})
+	}
+
+	result, err := jparser.ParseParams(data, []jparser.MetaData{{Path: "activities.43.21", ParamID: "okved"}})
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{{"okved": json.RawMessage(`"нет"`)}}
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParams() got result = %v, expectedRes = %v", result, expectedRes)
+	}
+}
+
+func TestParseParamsLiteralTokenKeys(t *testing.T) {
+	data := json.RawMessage(`{"@": "at", "#": "hash", "": "empty", "~any": "tilde",
+		"emails": [{"@": "a@example.com", "#": 1, "~any": true}, {"@": "b@example.com", "#": 2, "~any": false}]}`)
+
+	result, err := jparser.ParseParams(data, []jparser.MetaData{
+		{Path: `\@`, ParamID: "at", Required: true},
+		{Path: `\#`, ParamID: "hash", Required: true},
+		{Path: `[""]`, ParamID: "empty", Required: true},
+		{Path: `\~any`, ParamID: "tilde", Required: true},
+		{Path: `emails.[].\@`, ParamID: "email", Required: true},
+		{Path: `emails.[].\#`, ParamID: "number", Required: true},
+		{Path: `emails.[].\~any`, ParamID: "verified", Required: true},
+		{Path: "emails.[].@", ParamID: "index"},
+	})
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		{
+			"at": json.RawMessage(`"at"`), "hash": json.RawMessage(`"hash"`), "empty": json.RawMessage(`"empty"`),
+			"tilde": json.RawMessage(`"tilde"`), "email": json.RawMessage(`"a@example.com"`),
+			"number": json.RawMessage(`1`), "verified": json.RawMessage(`true`), "index": json.RawMessage(`0`),
+		},
+		{
+			"at": json.RawMessage(`"at"`), "hash": json.RawMessage(`"hash"`), "empty": json.RawMessage(`"empty"`),
+			"tilde": json.RawMessage(`"tilde"`), "email": json.RawMessage(`"b@example.com"`),
+			"number": json.RawMessage(`2`), "verified": json.RawMessage(`false`), "index": json.RawMessage(`1`),
+		},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParams() got result = %v, expectedRes = %v", result, expectedRes)
+	}
+}
+
+func TestParseParamsContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+
+	// The clock is read once per element, so it cancels the parse midway.
+	result, err := jparser.ParseParamsContextWithOptions(ctx, syntheticArray(10000), []jparser.MetaData{
+		{Path: "[].inn", ParamID: "inn"},
+		{Path: "[].&now", ParamID: "parsed_at"},
+	}, jparser.Options{Clock: func() time.Time {
+		if calls++; calls == 100 {
+			cancel()
+		}
+
+		return time.Time{}
+	}})
+	if !errors.Is(err, context.Canceled) || result != nil {
+		t.Errorf("ParseParamsContext() got result = %v, error = \"%v\", expected context.Canceled", result, err)
+	}
+
+	if calls != 100 {
+		t.Errorf("ParseParamsContext() parsed %d elements, expected 100", calls)
+	}
+
+	result, err = jparser.ParseParamsContext(context.Background(), oneObjectInJSON, []jparser.MetaData{
+		{Path: "inn", ParamID: "inn"},
+	})
+	if err != nil {
+		t.Fatalf("ParseParamsContext() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{{"inn": json.RawMessage(`"772473497153"`)}}
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParamsContext() got result = %v, expectedRes = %v", result, expectedRes)
+	}
+}
+
+func TestParseParamsCaseInsensitive(t *testing.T) {
+	data := json.RawMessage(`{"INN": "6663003127", "Ogrn": "1026605606620", "ogrn": "1026605606621",
+		"UL": {"Branches": [{"KPP": "771543001"}, {"kpp": "780243001"}]}}`)
+
+	meta := []jparser.MetaData{
+		{Path: "inn", ParamID: "inn"},
+		{Path: "OGRN", ParamID: "ogrn"},
+		{Path: "ul.branches.[].kpp", ParamID: "kpp"},
+	}
+
+	result, err := jparser.ParseParams(data, meta)
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	if expectedRes := []jparser.RawMessageSet{{}}; !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParams() got result = %v, expectedRes = %v", result, expectedRes)
+	}
+
+	result, err = jparser.ParseParamsWithOptions(data, meta, jparser.Options{CaseInsensitive: true})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		{
+			"inn":  json.RawMessage(`"6663003127"`),
+			"ogrn": json.RawMessage(`"1026605606620"`),
+			"kpp":  json.RawMessage(`"771543001"`),
+		},
+		{
+			"inn":  json.RawMessage(`"6663003127"`),
+			"ogrn": json.RawMessage(`"1026605606620"`),
+			"kpp":  json.RawMessage(`"780243001"`),
+		},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParamsWithOptions() got result = %v, expectedRes = %v", result, expectedRes)
+	}
+}
+
+func TestParseParamsMaxRows(t *testing.T) {
+	data := json.RawMessage(`{"phones": [{"number": "1"}, {"number": "2"}, {"number": "3"}],
+		"emails": [{"address": "a"}, {"address": "b"}, {"address": "c"}]}`)
+	meta := []jparser.MetaData{
+		{Path: "phones.[].number", ParamID: "phone"},
+		{Path: "emails.[].address", ParamID: "email"},
+	}
+
+	testTable := []struct {
+		name        string
+		maxRows     int
+		expectedErr bool
+	}{
+		{name: "unlimited", maxRows: 0},
+		{name: "exact", maxRows: 9},
+		{name: "exceeded", maxRows: 8, expectedErr: true},
+		{name: "exceeded by a single array", maxRows: 2, expectedErr: true},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := jparser.ParseParamsWithOptions(data, meta, jparser.Options{MaxRows: test.maxRows})
+			if test.expectedErr {
+				if !errors.Is(err, jparser.ErrTooManyRows) || result != nil {
+					t.Errorf("ParseParamsWithOptions() got result = %v, error = \"%v\", expected ErrTooManyRows",
+						result, err)
+				}
+
+				return
+			}
+
+			if err != nil || len(result) != 9 {
+				t.Errorf("ParseParamsWithOptions() got %d sets, error = \"%v\", expected 9 sets", len(result), err)
+			}
+		})
+	}
+}
+
+var (
+	productData = json.RawMessage(`{"inn": "6663003127", "ogrn": "1026605606620",
+		"phones": [{"number": "1", "region": "77"}, {"number": "2"}, {"number": "3", "region": "66"}],
+		"emails": [{"address": "a"}, {"address": "b"}],
+		"branches": [{"kpp": "771543001"}, {"kpp": "780243001"}, {"kpp": "667143001"}, {}]}`)
+	// productParts are the meta of productMeta by independent top-level node.
+	productParts = [][]jparser.MetaData{
+		{{Path: "inn", ParamID: "inn"}},
+		{{Path: "ogrn", ParamID: "ogrn"}},
+		{
+			{Path: "phones.[].number", ParamID: "phone"},
+			{Path: "phones.[].region", ParamID: "region"},
+			{Path: "phones.[].#", ParamID: "phones"},
+		},
+		{{Path: "emails.[].address", ParamID: "email"}},
+		{{Path: "branches.[].kpp", ParamID: "kpp"}, {Path: "branches.[].@", ParamID: "branch"}},
+	}
+	productMeta = concatMeta(productParts...)
+)
+
+func concatMeta(parts ...[]jparser.MetaData) []jparser.MetaData {
+	var res []jparser.MetaData
+	for _, part := range parts {
+		res = append(res, part...)
+	}
+
+	return res
+}
+
+// TestParseParamsProduct checks that the sets of independent nodes are
+// combined like a naive product of the sets of each node.
+func TestParseParamsProduct(t *testing.T) {
+	expected := []jparser.RawMessageSet{{}}
+
+	for _, part := range productParts {
+		res, err := jparser.ParseParams(productData, part)
+		if err != nil {
+			t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+		}
+
+		var product []jparser.RawMessageSet
+
+		for _, set1 := range expected {
+			for _, set2 := range res {
+				set := jparser.RawMessageSet{}
+				for _, s := range []jparser.RawMessageSet{set1, set2} {
+					for k, v := range s {
+						set[k] = v
+					}
+				}
+
+				product = append(product, set)
+			}
+		}
+
+		expected = product
+	}
+
+	result, err := jparser.ParseParams(productData, productMeta)
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	if len(result) != 3*2*4 || !reflect.DeepEqual(result, expected) {
+		t.Errorf("ParseParams() got result = %v, expectedRes = %v", result, expected)
+	}
+}
+
+func TestParseParamsDistinct(t *testing.T) {
+	data := json.RawMessage(`{"branches": [
+		{"kpp": "771543001", "address": {"region": "77", "city": "Москва"}},
+		{"kpp": "771543001", "address": {"city":"Москва","region":"77"}},
+		{"kpp": "780243001", "address": {"region": "78"}},
+		{"kpp": "771543001", "address": {"region": "77", "city": "Москва"}, "name": "Филиал"}
+	]}`)
+	meta := []jparser.MetaData{
+		{Path: "branches.[].kpp", ParamID: "kpp"},
+		{Path: "branches.[].address", ParamID: "address"},
+	}
+
+	result, err := jparser.ParseParams(data, meta)
+	if err != nil || len(result) != 4 {
+		t.Fatalf("ParseParams() got %d sets, error = \"%v\", expected 4 sets", len(result), err)
+	}
+
+	result, err = jparser.ParseParamsWithOptions(data, meta, jparser.Options{Distinct: true})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		{"kpp": json.RawMessage(`"771543001"`), "address": json.RawMessage(`{"region": "77", "city": "Москва"}`)},
+		{"kpp": json.RawMessage(`"780243001"`), "address": json.RawMessage(`{"region": "78"}`)},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParamsWithOptions() got result = %v, expectedRes = %v", result, expectedRes)
+	}
+}
+
+func TestParseParamsOrder(t *testing.T) {
+	expected, err := jparser.ParseParams(productData, productMeta)
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	// Sets of "phones" vary slower than those of "emails" and "branches".
+	first := jparser.RawMessageSet{
+		"inn": json.RawMessage(`"6663003127"`), "ogrn": json.RawMessage(`"1026605606620"`),
+		"phone": json.RawMessage(`"1"`), "region": json.RawMessage(`"77"`), "phones": json.RawMessage(`3`),
+		"email": json.RawMessage(`"a"`), "kpp": json.RawMessage(`"771543001"`), "branch": json.RawMessage(`0`),
+	}
+	if !reflect.DeepEqual(expected[0], first) || string(expected[1]["branch"]) != "1" ||
+		string(expected[4]["email"]) != `"b"` || string(expected[8]["phone"]) != `"2"` {
+		t.Fatalf("ParseParams() got result = %v, expected sets ordered by meta", expected)
+	}
+
+	for i := 0; i < 100; i++ {
+		result, err := jparser.ParseParams(productData, productMeta)
+		if err != nil {
+			t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+		}
+
+		if !reflect.DeepEqual(result, expected) {
+			t.Fatalf("ParseParams() run %d got result = %v, expected %v", i, result, expected)
+		}
+	}
+}
+
+func TestParseParamsDuplicateParamID(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "inn", ParamID: "inn"},
+		{Path: "IP.inn", ParamID: "inn"},
+		{Path: "ogrn", ParamID: "inn"},
+	}
+
+	testTable := []struct {
+		name        string
+		policy      jparser.DuplicatePolicy
+		expectedRes []jparser.RawMessageSet
+	}{
+		{
+			name:        "Last",
+			policy:      jparser.DuplicateLast,
+			expectedRes: []jparser.RawMessageSet{{"inn": json.RawMessage(`"318774600372150"`)}},
+		},
+		{
+			name:        "First",
+			policy:      jparser.DuplicateFirst,
+			expectedRes: []jparser.RawMessageSet{{"inn": json.RawMessage(`"772473497153"`)}},
+		},
+		{
+			name:        "Array",
+			policy:      jparser.DuplicateArray,
+			expectedRes: []jparser.RawMessageSet{{"inn": json.RawMessage(`["772473497153","318774600372150"]`)}},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := jparser.ParseParamsWithOptions(oneObjectInJSON, meta,
+				jparser.Options{DuplicateParamID: test.policy})
+			if err != nil {
+				t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+			}
+
+			if !reflect.DeepEqual(result, test.expectedRes) {
+				t.Errorf("ParseParamsWithOptions() got result = %s, expectedRes = %s", result, test.expectedRes)
+			}
+		})
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		result, err := jparser.ParseParamsWithOptions(oneObjectInJSON, meta,
+			jparser.Options{DuplicateParamID: jparser.DuplicateError})
+
+		var metaErr *jparser.MetaError
+		if !errors.As(err, &metaErr) || metaErr.ParamID != "inn" || metaErr.Path != "IP.inn" || result != nil {
+			t.Errorf("ParseParamsWithOptions() got result = %v, error = \"%v\", expected *MetaError for IP.inn",
+				result, err)
+		}
+	})
+
+	t.Run("Required", func(t *testing.T) {
+		_, err := jparser.ParseParamsWithOptions(oneObjectInJSON, []jparser.MetaData{
+			{Path: "inn", ParamID: "inn"},
+			{Path: "IP.inn", ParamID: "inn", Required: true},
+		}, jparser.Options{DuplicateParamID: jparser.DuplicateFirst})
+
+		var missingErr *jparser.MissingError
+		if !errors.As(err, &missingErr) || missingErr.ParamID != "inn" {
+			t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected *MissingError for \"inn\"", err)
+		}
+	})
+}
+
+func TestParseParamsNull(t *testing.T) {
+	data := json.RawMessage(`{"inn": "6663003127", "ogrn": null, "UL": null,
+		"branches": [{"kpp": null}, {}]}`)
+
+	result, err := jparser.ParseParams(data, []jparser.MetaData{
+		{Path: "ogrn", ParamID: "ogrn"},
+		{Path: "okpo", ParamID: "okpo"},
+		{Path: "UL.kpp", ParamID: "ul_kpp"},
+		{Path: "branches.[].kpp", ParamID: "kpp"},
+	})
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		{"ogrn": json.RawMessage(`null`), "kpp": json.RawMessage(`null`)},
+		{"ogrn": json.RawMessage(`null`)},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParams() got result = %s, expectedRes = %s", result, expectedRes)
+	}
+}
+
+func TestParseParamsUnmatched(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "[].inn", ParamID: "inn"},
+		{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+		{Path: "[].UL.branches.[].@", ParamID: "branch"},
+		{Path: "[].UL.branches.[].non_existing", ParamID: "non_existing"},
+		{Path: "[].UL.okved", ParamID: "okved", Default: json.RawMessage(`null`)},
+	}
+
+	result, unmatched, err := jparser.ParseParamsUnmatched(oneElementInArrayJSON, meta)
+	if err != nil {
+		t.Fatalf("ParseParamsUnmatched() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes, err := jparser.ParseParams(oneElementInArrayJSON, meta)
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParamsUnmatched() got result = %v, expectedRes = %v", result, expectedRes)
+	}
+
+	if expected := []string{"non_existing", "okved"}; !reflect.DeepEqual(unmatched, expected) {
+		t.Errorf("ParseParamsUnmatched() got unmatched = %v, expected %v", unmatched, expected)
+	}
+}
+
+func TestParseParamsLength(t *testing.T) {
+	result, err := jparser.ParseParams(oneElementInArrayJSON, []jparser.MetaData{
+		{Path: "[].UL.legalName.full.#", ParamID: "name_len"},
+		{Path: "[].UL.legalName.#", ParamID: "name_keys"},
+		{Path: "[].UL.branches.#", ParamID: "branches"},
+		{Path: "[].UL.legalAddress.parsedAddressRFFias.fiasId.#", ParamID: "fias_id_len"},
+	})
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{{
+		"name_len":  json.RawMessage(`57`),
+		"name_keys": json.RawMessage(`4`),
+		"branches":  json.RawMessage(`5`),
+	}}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParams() got result = %s, expectedRes = %s", result, expectedRes)
+	}
+}
+
+func TestParseParamsType(t *testing.T) {
+	testTable := []struct {
+		name         string
+		data         json.RawMessage
+		path         string
+		expectedType string
+	}{
+		{name: "object", data: oneElementInArrayJSON, path: "[].UL.&type", expectedType: `"object"`},
+		{name: "array", data: oneElementInArrayJSON, path: "[].UL.branches.&type", expectedType: `"array"`},
+		{name: "string", data: oneElementInArrayJSON, path: "[].inn.&type", expectedType: `"string"`},
+		{
+			name:         "number",
+			data:         oneElementInArrayJSON,
+			path:         "[].UL.legalAddress.parsedAddressRFFias.fiasId.&type",
+			expectedType: `"number"`,
+		},
+		{
+			name:         "boolean",
+			data:         oneElementInArrayJSON,
+			path:         "[].briefReport.summary.greenStatements.&type",
+			expectedType: `"boolean"`,
+		},
+		{name: "null", data: json.RawMessage(`{"ogrn": null}`), path: "ogrn.&type", expectedType: `"null"`},
+		{name: "missing", data: oneElementInArrayJSON, path: "[].UL.okved.&type"},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := jparser.ParseParams(testCase.data, []jparser.MetaData{{Path: testCase.path, ParamID: "type"}})
+			if err != nil {
+				t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+			}
+
+			expectedRes := []jparser.RawMessageSet{{}}
+			if testCase.expectedType != "" {
+				expectedRes[0]["type"] = json.RawMessage(testCase.expectedType)
+			}
+
+			if !reflect.DeepEqual(result, expectedRes) {
+				t.Errorf("ParseParams() got result = %s, expectedRes = %s", result, expectedRes)
+			}
+		})
+	}
+}
+
+func TestParseParamsKeys(t *testing.T) {
+	testTable := []struct {
+		name        string
+		data        json.RawMessage
+		meta        []jparser.MetaData
+		expectedRes []jparser.RawMessageSet
+	}{
+		{
+			name: "Object",
+			data: oneElementInArrayJSON,
+			meta: []jparser.MetaData{{Path: "[].UL.&keys", ParamID: "ul_fields"}},
+			expectedRes: []jparser.RawMessageSet{{
+				"ul_fields": json.RawMessage(`["branches","history","kpp","legalAddress","legalName","okato","okfs",` +
+					`"okogu","okopf","okpo","oktmo","opf","registrationDate","status"]`),
+			}},
+		},
+		{
+			name:        "Empty object",
+			data:        oneObjectInJSON,
+			meta:        []jparser.MetaData{{Path: "contactPhones.&keys", ParamID: "phones"}},
+			expectedRes: []jparser.RawMessageSet{{"phones": json.RawMessage(`[]`)}},
+		},
+		{
+			name:        "Not an object",
+			data:        oneObjectInJSON,
+			meta:        []jparser.MetaData{{Path: "inn.&keys", ParamID: "inn_fields"}},
+			expectedRes: []jparser.RawMessageSet{{}},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := jparser.ParseParams(testCase.data, testCase.meta)
+			if err != nil {
+				t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+			}
+
+			if !reflect.DeepEqual(result, testCase.expectedRes) {
+				t.Errorf("ParseParams() got result = %s, expectedRes = %s", result, testCase.expectedRes)
+			}
+		})
+	}
+}
+
+func TestParseParamsIndexLabels(t *testing.T) {
+	data := json.RawMessage(`{"UL": {"branches": [{"kpp": "771543001", "phones": [{"number": "1"}, {"number": "2"}]},
+		{"kpp": "780243001"}]},
+		"history": {"kpps": [{"value": "667101001"}, {"value": "667143001"}]}}`)
+
+	result, err := jparser.ParseParamsWithOptions(data, []jparser.MetaData{
+		{Path: "UL.branches.[].kpp", ParamID: "kpp"},
+		{Path: "UL.branches.[].phones.[].number", ParamID: "phone"},
+		{Path: "history.kpps.[].value", ParamID: "history_kpp"},
+	}, jparser.Options{IndexLabelPrefix: "@"})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	row := func(branch, phone, history int) jparser.RawMessageSet {
+		kpps := []string{`"771543001"`, `"780243001"`}
+		historyKpps := []string{`"667101001"`, `"667143001"`}
+
+		set := jparser.RawMessageSet{
+			"kpp":              json.RawMessage(kpps[branch]),
+			"@UL.branches.[]":  json.RawMessage(strconv.Itoa(branch)),
+			"history_kpp":      json.RawMessage(historyKpps[history]),
+			"@history.kpps.[]": json.RawMessage(strconv.Itoa(history)),
+		}
+
+		if phone >= 0 {
+			set["phone"] = json.RawMessage(strconv.Quote(strconv.Itoa(phone + 1)))
+			set["@UL.branches.[].phones.[]"] = json.RawMessage(strconv.Itoa(phone))
+		}
+
+		return set
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		row(0, 0, 0), row(0, 0, 1), row(0, 1, 0), row(0, 1, 1), row(1, -1, 0), row(1, -1, 1),
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParamsWithOptions() got result = %s, expectedRes = %s", result, expectedRes)
+	}
+}
+
+func TestParseParamsScalarAsArray(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "inn", ParamID: "inn"},
+		{Path: "UL.branches.[].kpp", ParamID: "kpp"},
+		{Path: "UL.branches.[].@", ParamID: "branch"},
+	}
+
+	opts := jparser.Options{ScalarAsArray: true, Strict: true}
+
+	expectedRes, err := jparser.ParseParamsWithOptions(
+		json.RawMessage(`{"inn": "6663003127", "UL": {"branches": [{"kpp": "771543001"}]}}`), meta, opts)
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	data := json.RawMessage(`{"inn": "6663003127", "UL": {"branches": {"kpp": "771543001"}}}`)
+
+	result, err := jparser.ParseParamsWithOptions(data, meta, opts)
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParamsWithOptions() got result = %s, expectedRes = %s", result, expectedRes)
+	}
+
+	var unmarshalErr *jparser.UnmarshalError
+	if _, err := jparser.ParseParams(data, meta); !errors.As(err, &unmarshalErr) {
+		t.Errorf("ParseParams() got error = \"%v\", expected *UnmarshalError", err)
+	}
+}
+
+func TestParseParamsObjectAsArray(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "branches.[].kpp", ParamID: "kpp"},
+		{Path: "branches.[].@", ParamID: "index"},
+		{Path: "branches.[].%", ParamID: "id"},
+	}
+
+	opts := jparser.Options{ObjectAsArray: true, Strict: true}
+
+	testTable := []struct {
+		name        string
+		data        json.RawMessage
+		expectedRes []jparser.RawMessageSet
+	}{
+		{
+			name: "Object",
+			data: json.RawMessage(`{"branches": {"b2": {"kpp": "780243001"}, "b1": {"kpp": "771543001"}}}`),
+			expectedRes: []jparser.RawMessageSet{
+				{"kpp": json.RawMessage(`"771543001"`), "index": json.RawMessage(`0`), "id": json.RawMessage(`"b1"`)},
+				{"kpp": json.RawMessage(`"780243001"`), "index": json.RawMessage(`1`), "id": json.RawMessage(`"b2"`)},
+			},
+		},
+		{
+			name: "Array",
+			data: json.RawMessage(`{"branches": [{"kpp": "771543001"}, {"kpp": "780243001"}]}`),
+			expectedRes: []jparser.RawMessageSet{
+				{"kpp": json.RawMessage(`"771543001"`), "index": json.RawMessage(`0`)},
+				{"kpp": json.RawMessage(`"780243001"`), "index": json.RawMessage(`1`)},
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := jparser.ParseParamsWithOptions(testCase.data, meta, opts)
+			if err != nil {
+				t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+			}
+
+			if !reflect.DeepEqual(result, testCase.expectedRes) {
+				t.Errorf("ParseParamsWithOptions() got result = %s, expectedRes = %s", result, testCase.expectedRes)
+			}
+		})
+	}
+}
+
+func TestParseParamsTransform(t *testing.T) {
+	errNotDate := errors.New("not a date")
+
+	toRussianDate := func(value json.RawMessage) (json.RawMessage, error) {
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return nil, err
+		}
+
+		date, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return nil, errNotDate
+		}
+
+		return json.RawMessage(strconv.Quote(date.Format("02.01.2006"))), nil
+	}
+
+	result, err := jparser.ParseParams(oneElementInArrayJSON, []jparser.MetaData{
+		{Path: "[].inn", ParamID: "inn"},
+		{Path: "[].UL.legalName.date", ParamID: "name_date", Transform: toRussianDate},
+	})
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{{
+		"inn":       json.RawMessage(`"6663003127"`),
+		"name_date": json.RawMessage(`"21.06.2017"`),
+	}}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParams() got result = %s, expectedRes = %s", result, expectedRes)
+	}
+
+	_, err = jparser.ParseParams(oneElementInArrayJSON, []jparser.MetaData{
+		{Path: "[].UL.legalName.full", ParamID: "name", Transform: toRussianDate},
+	})
+
+	var transformErr *jparser.TransformError
+	if !errors.As(err, &transformErr) || transformErr.ParamID != "name" ||
+		transformErr.Path != "[0].UL.legalName.full" || !errors.Is(err, errNotDate) {
+		t.Errorf("ParseParams() got error = \"%v\", expected *TransformError for name wrapping %v", err, errNotDate)
+	}
+}
+
+func TestParseParamsTrailingEmptySegment(t *testing.T) {
+	var companies []struct {
+		UL struct {
+			LegalName json.RawMessage `json:"legalName"`
+		} `json:"UL"`
+	}
+
+	if err := json.Unmarshal(oneElementInArrayJSON, &companies); err != nil {
+		t.Fatalf("json.Unmarshal() got error = \"%v\", expected nil", err)
+	}
+
+	legalName := companies[0].UL.LegalName
+
+	testTable := []struct {
+		name        string
+		data        json.RawMessage
+		meta        []jparser.MetaData
+		expectedRes []jparser.RawMessageSet
+	}{
+		{
+			name:        "Nested object",
+			data:        oneElementInArrayJSON,
+			meta:        []jparser.MetaData{{Path: "[].UL.legalName.", ParamID: "legal_name"}},
+			expectedRes: []jparser.RawMessageSet{{"legal_name": legalName}},
+		},
+		{
+			name: "Alongside paths below it",
+			data: oneElementInArrayJSON,
+			meta: []jparser.MetaData{
+				{Path: "[].UL.legalName.", ParamID: "legal_name"},
+				{Path: "[].UL.legalName.date", ParamID: "date"},
+			},
+			expectedRes: []jparser.RawMessageSet{
+				{"legal_name": legalName, "date": json.RawMessage(`"2017-06-21"`)},
+			},
+		},
+		{
+			name: "Whole array",
+			data: json.RawMessage(`[{"inn": "6663003127"}, {"inn": "7708004767"}]`),
+			meta: []jparser.MetaData{{Path: "[].", ParamID: "company"}, {Path: "[].inn", ParamID: "inn"}},
+			expectedRes: []jparser.RawMessageSet{{
+				"company": json.RawMessage(`[{"inn": "6663003127"}, {"inn": "7708004767"}]`),
+				"inn":     json.RawMessage(`"6663003127"`),
+			}, {
+				"company": json.RawMessage(`[{"inn": "6663003127"}, {"inn": "7708004767"}]`),
+				"inn":     json.RawMessage(`"7708004767"`),
+			}},
+		},
+		{
+			name:        "Empty key",
+			data:        json.RawMessage(`{"a": {"": 1}}`),
+			meta:        []jparser.MetaData{{Path: "a.", ParamID: "a"}, {Path: `a.[""]`, ParamID: "empty"}},
+			expectedRes: []jparser.RawMessageSet{{"a": json.RawMessage(`{"": 1}`), "empty": json.RawMessage(`1`)}},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := jparser.ParseParams(testCase.data, testCase.meta)
+			if err != nil {
+				t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+			}
+
+			if !reflect.DeepEqual(result, testCase.expectedRes) {
+				t.Errorf("ParseParams() got result = %s, expectedRes = %s", result, testCase.expectedRes)
+			}
+		})
+	}
+}
+
+func TestParseParamsMaxDepth(t *testing.T) {
+	const depth = 50
+
+	data := json.RawMessage(strings.Repeat(`{"a": `, depth) + `{"kpp": "771543001"}` + strings.Repeat(`}`, depth))
+	path := strings.Repeat("a.", depth) + "kpp"
+
+	testTable := []struct {
+		name        string
+		meta        []jparser.MetaData
+		opts        jparser.Options
+		expectedErr error
+	}{
+		{
+			name: "Path within the default",
+			meta: []jparser.MetaData{{Path: path, ParamID: "kpp"}},
+		},
+		{
+			name:        "Path",
+			meta:        []jparser.MetaData{{Path: path, ParamID: "kpp"}},
+			opts:        jparser.Options{MaxDepth: 10},
+			expectedErr: jparser.ErrMaxDepthExceeded,
+		},
+		{
+			name:        "Recursive descent",
+			meta:        []jparser.MetaData{{Path: "**.kpp", ParamID: "kpp"}},
+			opts:        jparser.Options{MaxDepth: 20, MaxDescentDepth: 1000},
+			expectedErr: jparser.ErrMaxDepthExceeded,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := jparser.ParseParamsWithOptions(data, testCase.meta, testCase.opts)
+			if !errors.Is(err, testCase.expectedErr) {
+				t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected \"%v\"", err, testCase.expectedErr)
+			}
+
+			expectedRes := []jparser.RawMessageSet{{"kpp": json.RawMessage(`"771543001"`)}}
+			if err == nil && !reflect.DeepEqual(result, expectedRes) {
+				t.Errorf("ParseParamsWithOptions() got result = %s, expectedRes = %s", result, expectedRes)
+			}
+		})
+	}
+}
+
+func TestParseParamsMaxArrayElements(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "[].inn", ParamID: "inn"},
+		{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+		{Path: "[].UL.branches.[].@", ParamID: "branch"},
+		{Path: "[].UL.branches.[].#", ParamID: "branches"},
+	}
+
+	opts := jparser.Options{MaxArrayElements: 2}
+
+	result, err := jparser.ParseParamsWithOptions(oneElementInArrayJSON, meta, opts)
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	inn, branches := json.RawMessage(`"6663003127"`), json.RawMessage(`5`)
+	expectedRes := []jparser.RawMessageSet{
+		{"inn": inn, "kpp": json.RawMessage(`"771543001"`), "branch": json.RawMessage(`0`), "branches": branches},
+		{"inn": inn, "kpp": json.RawMessage(`"771543002"`), "branch": json.RawMessage(`1`), "branches": branches},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParamsWithOptions() got result = %s, expectedRes = %s", result, expectedRes)
+	}
+
+	p, err := jparser.NewParser(meta, opts)
+	if err != nil {
+		t.Fatalf("NewParser() got error = \"%v\", expected nil", err)
+	}
+
+	var streamed []jparser.RawMessageSet
+	if err := p.ParseStream(bytes.NewReader(syntheticArray(5)), func(set jparser.RawMessageSet) error {
+		streamed = append(streamed, set)
+		return nil
+	}); err != nil {
+		t.Fatalf("ParseStream() got error = \"%v\", expected nil", err)
+	}
+
+	if len(streamed) != 2 || string(streamed[1]["inn"]) != `"0000000001"` {
+		t.Errorf("ParseStream() got result = %s, expected the sets of the first 2 elements", streamed)
+	}
+}
+
+func TestParseParamsExists(t *testing.T) {
+	data := json.RawMessage(`{"UL": {"branches": [{"kpp": "771543001", "name": "Филиал"}, {"kpp": "780243001"}]},
+		"IP": null}`)
+
+	result, err := jparser.ParseParamsWithOptions(data, []jparser.MetaData{
+		{Path: "UL.branches.&exists", ParamID: "has_branches"},
+		{Path: "UL.okved.&exists", ParamID: "has_okved"},
+		{Path: "IP.&exists", ParamID: "has_ip"},
+		{Path: "UL.branches.[].kpp", ParamID: "kpp"},
+		{Path: "UL.branches.[].name.&exists", ParamID: "has_name"},
+	}, jparser.Options{Strict: true})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	present, absent := json.RawMessage(`true`), json.RawMessage(`false`)
+	expectedRes := []jparser.RawMessageSet{
+		{
+			"has_branches": present, "has_okved": absent, "has_ip": present,
+			"kpp": json.RawMessage(`"771543001"`), "has_name": present,
+		},
+		{
+			"has_branches": present, "has_okved": absent, "has_ip": present,
+			"kpp": json.RawMessage(`"780243001"`), "has_name": absent,
+		},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParamsWithOptions() got result = %s, expectedRes = %s", result, expectedRes)
+	}
+}
+
+func TestParseParamsParentToken(t *testing.T) {
+	result, err := jparser.ParseParams(oneElementInArrayJSON, []jparser.MetaData{
+		{Path: "[].UL.branches.[].date", ParamID: "branch_date"},
+		{Path: "[].UL.branches.[].^.kpp", ParamID: "legal_kpp"},
+		{Path: "[].UL.legalName.^.^.inn", ParamID: "inn"},
+	})
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	dates := []string{"2008-10-03", "2011-09-02", "2017-11-22", "2018-05-24", "2021-09-09"}
+	if len(result) != len(dates) {
+		t.Fatalf("ParseParams() got %d sets, expected %d", len(result), len(dates))
+	}
+
+	for i, set := range result {
+		expected := jparser.RawMessageSet{
+			"branch_date": json.RawMessage(strconv.Quote(dates[i])),
+			"legal_kpp":   json.RawMessage(`"667101001"`),
+			"inn":         json.RawMessage(`"6663003127"`),
+		}
+
+		if !reflect.DeepEqual(set, expected) {
+			t.Errorf("ParseParams() got set %d = %s, expected %s", i, set, expected)
+		}
+	}
+}
+
+func TestParseParamsDetectDuplicateKeys(t *testing.T) {
+	data := json.RawMessage(`{"UL": {"inn": "6663003127", "kpp": "667101001", "inn": "0000000000"},
+		"IP": {"ogrnip": "1", "ogrnip": "2"}}`)
+
+	tests := []struct {
+		name     string
+		meta     []jparser.MetaData
+		detect   bool
+		expected []jparser.RawMessageSet
+		key      string
+	}{
+		{
+			name:     "last value kept without detection",
+			meta:     []jparser.MetaData{{Path: "UL.inn", ParamID: "inn"}},
+			expected: []jparser.RawMessageSet{{"inn": json.RawMessage(`"0000000000"`)}},
+		},
+		{
+			name:   "repeated key on path",
+			meta:   []jparser.MetaData{{Path: "UL.kpp", ParamID: "kpp"}},
+			detect: true,
+			key:    "inn",
+		},
+		{
+			name:     "repeated key off path",
+			meta:     []jparser.MetaData{{Path: "IP", ParamID: "ip"}},
+			detect:   true,
+			expected: []jparser.RawMessageSet{{"ip": json.RawMessage(`{"ogrnip": "1", "ogrnip": "2"}`)}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := jparser.ParseParamsWithOptions(data, test.meta,
+				jparser.Options{DetectDuplicateKeys: test.detect})
+			if test.key == "" {
+				if err != nil {
+					t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+				}
+
+				if !reflect.DeepEqual(result, test.expected) {
+					t.Errorf("ParseParamsWithOptions() got %s, expected %s", result, test.expected)
+				}
+
+				return
+			}
+
+			var dupErr *jparser.DuplicateKeyError
+			if !errors.As(err, &dupErr) {
+				t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected *DuplicateKeyError", err)
+			}
+
+			if dupErr.Key != test.key {
+				t.Errorf("DuplicateKeyError.Key got %q, expected %q", dupErr.Key, test.key)
+			}
+
+			var unmarshalErr *jparser.UnmarshalError
+			if !errors.As(err, &unmarshalErr) || unmarshalErr.Path != "UL" {
+				t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected *UnmarshalError at UL", err)
+			}
+		})
+	}
+}
+
+func TestParseParamsPlainFastPath(t *testing.T) {
+	plainMeta := []jparser.MetaData{
+		{Path: "inn", ParamID: "inn"},
+		{Path: "IP.fio", ParamID: "fio"},
+		{Path: "IP.status.statusString", ParamID: "status"},
+		{Path: "IP.status", ParamID: "status_object"},
+		{Path: "IP.status.statusString", ParamID: "status"},
+		{Path: "", ParamID: "document"},
+		{Path: "IP.kpp", ParamID: "kpp", Default: json.RawMessage(`"000000000"`)},
+		{Path: "UL.kpp", ParamID: "ul_kpp"},
+		{Path: "briefReport.summary.greenStatements", ParamID: "green"},
+		{Path: "contactPhones.phones", ParamID: "phones"},
+	}
+
+	tests := []struct {
+		name string
+		data json.RawMessage
+		meta []jparser.MetaData
+		opts jparser.Options
+	}{
+		{name: "Scalar paths", data: oneObjectInJSON, meta: plainMeta},
+		{
+			name: "Companions",
+			data: oneObjectInJSON,
+			meta: plainMeta,
+			opts: jparser.Options{
+				ParentPathSuffix: "_parent", OffsetSuffix: "_offset", ProvenanceSuffix: "_provenance",
+				EnclosingObjectSuffix: "_object",
+			},
+		},
+		{
+			name: "Case insensitive",
+			data: oneObjectInJSON,
+			meta: []jparser.MetaData{{Path: "ip.FIO", ParamID: "fio"}},
+			opts: jparser.Options{CaseInsensitive: true},
+		},
+		{name: "Strict", data: oneObjectInJSON, meta: plainMeta, opts: jparser.Options{Strict: true}},
+		{name: "Array document", data: oneElementInArrayJSON, meta: plainMeta},
+		{name: "Below an array", data: oneElementInArrayJSON, meta: sharedPrefixMeta},
+		{name: "Malformed", data: brokenJSON, meta: sharedPrefixMeta},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := jparser.ParseParamsWithOptions(test.data, test.meta, test.opts)
+
+			general, genErr := jparser.NewGeneralPathParser(test.meta, test.opts)
+			if genErr != nil {
+				t.Fatalf("NewGeneralPathParser() got error = \"%v\", expected nil", genErr)
+			}
+
+			expected, expectedErr := general.ParseParams(test.data)
+
+			if !reflect.DeepEqual(err, expectedErr) {
+				t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected \"%v\"", err, expectedErr)
+			}
+
+			if !reflect.DeepEqual(result, expected) {
+				t.Errorf("ParseParamsWithOptions() got %s, expected %s", result, expected)
+			}
+		})
+	}
+}
+
+func TestParseParamsDeferRequired(t *testing.T) {
+	tests := []struct {
+		name     string
+		meta     []jparser.MetaData
+		expected []jparser.IncompleteSet
+	}{
+		{
+			name: "Missing from some sets",
+			meta: []jparser.MetaData{
+				{Path: "[].UL.branches.[].date", ParamID: "date1"},
+				{Path: "[].IP.status.date", ParamID: "date2", Required: true},
+			},
+			expected: []jparser.IncompleteSet{{Index: 0, ParamIDs: []string{"date2"}}},
+		},
+		{
+			name: "Missing from every set",
+			meta: []jparser.MetaData{
+				{Path: "[].UL.branches.[].date", ParamID: "date1", Required: true},
+				{Path: "[].IP.status.date", ParamID: "date2", Required: true},
+			},
+			expected: []jparser.IncompleteSet{
+				{Index: 0, ParamIDs: []string{"date1", "date2"}},
+				{Index: 1, ParamIDs: []string{"date1"}},
+				{Index: 2, ParamIDs: []string{"date1"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var missingErr *jparser.MissingError
+			if _, err := jparser.ParseParams(multipleElementsInArrayJSON, test.meta); !errors.As(err, &missingErr) {
+				t.Fatalf("ParseParams() got error = \"%v\", expected *MissingError", err)
+			}
+
+			opts := jparser.Options{DeferRequired: true}
+			expectedErr := &jparser.ValidationError{Sets: test.expected}
+
+			result, err := jparser.ParseParamsWithOptions(multipleElementsInArrayJSON, test.meta, opts)
+			if result != nil || !reflect.DeepEqual(err, expectedErr) {
+				t.Errorf("ParseParamsWithOptions() got %s, error = \"%v\", expected nil, \"%v\"", result, err, expectedErr)
+			}
+
+			err = jparser.ValidateWithOptions(multipleElementsInArrayJSON, test.meta, opts)
+			if !reflect.DeepEqual(err, expectedErr) {
+				t.Errorf("ValidateWithOptions() got error = \"%v\", expected \"%v\"", err, expectedErr)
+			}
+		})
+	}
+
+	p, err := jparser.NewParser([]jparser.MetaData{{Path: "[].inn", ParamID: "inn", Required: true}},
+		jparser.Options{DeferRequired: true})
+	if err != nil {
+		t.Fatalf("NewParser() got error = \"%v\", expected nil", err)
+	}
+
+	err = p.ParseStream(strings.NewReader(`[{"inn": "1"}, {"inn": "2"}, {"ogrn": "3"}]`),
+		func(jparser.RawMessageSet) error { return nil })
+
+	expectedErr := &jparser.ValidationError{Sets: []jparser.IncompleteSet{{Index: 2, ParamIDs: []string{"inn"}}}}
+	if !reflect.DeepEqual(err, expectedErr) {
+		t.Errorf("ParseStream() got error = \"%v\", expected \"%v\"", err, expectedErr)
+	}
+
+	if expected := "error: required params missing, set 2: inn"; err.Error() != expected {
+		t.Errorf("Error() got %q, expected %q", err.Error(), expected)
+	}
+}
+
+func TestParseParamsDisableMemoization(t *testing.T) {
+	for _, meta := range [][]jparser.MetaData{sharedPrefixMeta, streamMeta} {
+		expected, err := jparser.ParseParams(oneElementInArrayJSON, meta)
+		if err != nil {
+			t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+		}
+
+		result, err := jparser.ParseParamsWithOptions(oneElementInArrayJSON, meta,
+			jparser.Options{DisableMemoization: true})
+		if err != nil {
+			t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+		}
+
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("ParseParamsWithOptions() got %s, expected %s", result, expected)
+		}
+	}
+}
+
+func TestParseParamsContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		meta []jparser.MetaData
+	}{
+		{name: "Descent", meta: []jparser.MetaData{{Path: "**.inn", ParamID: "inn"}}},
+		{name: "Aggregate", meta: []jparser.MetaData{{Path: "[].inn~distinctcount", ParamID: "inns"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := jparser.ParseParamsContextWithOptions(ctx, syntheticArray(10), test.meta, jparser.Options{})
+			if !errors.Is(err, context.Canceled) || result != nil {
+				t.Errorf("ParseParamsContext() got result = %v, error = \"%v\", expected context.Canceled", result, err)
+			}
+		})
+	}
+}
+
+func TestParseParamsMinPopulatedParams(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "[].inn", ParamID: "inn"},
+		{Path: "[].IP.dissolutionDate", ParamID: "dissolution_date"},
+		{Path: "[].IP.status.date", ParamID: "status_date"},
+	}
+
+	result, err := jparser.ParseParamsWithOptions(multipleElementsInArrayJSON, meta, jparser.Options{
+		MinPopulatedParams: 2,
+	})
+
+	var populatedErr *jparser.UnderpopulatedError
+	if !errors.As(err, &populatedErr) || populatedErr.Index != 0 || populatedErr.Populated != 1 {
+		t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected *UnderpopulatedError for set 0", err)
+	}
+
+	if result != nil {
+		t.Errorf("ParseParamsWithOptions() got result = %v, expected nil", result)
+	}
+
+	result, err = jparser.ParseParamsWithOptions(multipleElementsInArrayJSON, meta, jparser.Options{
+		MinPopulatedParams: 2,
+		DropUnderpopulated: true,
+	})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{
+		{
+			"inn":              json.RawMessage(`"772473497153"`),
+			"dissolution_date": json.RawMessage(`"2017-05-05"`),
+			"status_date":      json.RawMessage(`"2017-05-05"`),
+		},
+		{
+			"inn":              json.RawMessage(`"772473497153"`),
+			"dissolution_date": json.RawMessage(`"2013-03-13"`),
+			"status_date":      json.RawMessage(`"2013-03-13"`),
+		},
+	}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		got, _ := json.MarshalIndent(result, "", "  ")
+		expected, _ := json.MarshalIndent(expectedRes, "", "  ")
+		t.Errorf("ParseParamsWithOptions() got result = %s\nexpectedRes = %s", got, expected)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testTable := []struct {
+		name        string
+		meta        []jparser.MetaData
+		expectedErr error
+	}{
+		{
+			name: "Valid",
+			meta: []jparser.MetaData{
+				{Path: "[].UL.branches.[].kpp", ParamID: "kpp", Required: true},
+				{Path: "[].inn", ParamID: "inn", Required: true},
+				{Path: "[].UL.legalAddress.parsedAddressRF.non-existing", ParamID: "non-existing"},
+			},
+		},
+		{
+			name: "Required missing",
+			meta: []jparser.MetaData{
+				{Path: "[].UL.branches.[].kpp", ParamID: "kpp", Required: true},
+				{Path: "[].UL.branches.[].name", ParamID: "name", Required: true},
+			},
+			expectedErr: &jparser.MissingError{ParamID: "name", Path: "[0].UL.branches.[0].name"},
+		},
+		{
+			name: "Required missing parent",
+			meta: []jparser.MetaData{
+				{Path: "[].IP.fio", ParamID: "fio", Required: true},
+			},
+			expectedErr: &jparser.MissingError{ParamID: "fio", Path: "[0].IP"},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			err := jparser.Validate(oneElementInArrayJSON, test.meta)
+			if !reflect.DeepEqual(err, test.expectedErr) {
+				t.Errorf("Validate() got error = \"%v\", expected \"%v\"", err, test.expectedErr)
+			}
+		})
+	}
+
+	if err := jparser.Validate(json.RawMessage(`{"kpps": []}`), []jparser.MetaData{
+		{Path: "kpps.[].kpp", ParamID: "kpp", Required: true},
+	}); !reflect.DeepEqual(err, &jparser.MissingError{ParamID: "kpp", Path: "kpps.[]"}) {
+		t.Errorf("Validate() got error = \"%v\", expected *MissingError for \"kpps.[]\"", err)
+	}
+
+	meta := []jparser.MetaData{
+		{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+		{Path: "[].UL.branches.[].date", ParamID: "date"},
+		{Path: "[].UL.history.kpps.[].kpp", ParamID: "history_kpp"},
+	}
+
+	validateAllocs := testing.AllocsPerRun(10, func() { _ = jparser.Validate(oneElementInArrayJSON, meta) })
+	parseAllocs := testing.AllocsPerRun(10, func() { _, _ = jparser.ParseParams(oneElementInArrayJSON, meta) })
+
+	if validateAllocs >= parseAllocs {
+		t.Errorf("Validate() allocs = %v, expected less than ParseParams() allocs = %v", validateAllocs, parseAllocs)
+	}
 }
 
 var (
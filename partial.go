@@ -0,0 +1,96 @@
+package jparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// validPrefix returns the valid prefix of the malformed JSON document data,
+// see Options.BestEffort, with the offset and error of the failure.
+//
+// nolint:gocognit,cyclop
+func validPrefix(data json.RawMessage) (json.RawMessage, int64, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	type container struct {
+		object  bool
+		members int
+	}
+
+	var (
+		buf   bytes.Buffer
+		stack []container
+		key   json.RawMessage
+	)
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].object {
+					buf.WriteByte('}')
+				} else {
+					buf.WriteByte(']')
+				}
+			}
+
+			return buf.Bytes(), dec.InputOffset(), err
+		}
+
+		if s, ok := tok.(string); ok && len(stack) > 0 && stack[len(stack)-1].object && key == nil {
+			key, _ = marshalRaw(s) // strings always marshal
+
+			continue
+		}
+
+		if delim, ok := tok.(json.Delim); ok && (delim == '}' || delim == ']') {
+			buf.WriteByte(byte(delim))
+			stack = stack[:len(stack)-1]
+
+			if len(stack) == 0 {
+				return buf.Bytes(), dec.InputOffset(), nil
+			}
+
+			continue
+		}
+
+		if len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			if top.members > 0 {
+				buf.WriteByte(',')
+			}
+
+			top.members++
+
+			if top.object {
+				buf.Write(key)
+				buf.WriteByte(':')
+
+				key = nil
+			}
+		}
+
+		switch tok := tok.(type) {
+		case json.Delim:
+			buf.WriteByte(byte(tok))
+			stack = append(stack, container{object: tok == '{'})
+
+			continue
+		case string:
+			quoted, _ := marshalRaw(tok) // strings always marshal
+			buf.Write(quoted)
+		case json.Number:
+			buf.WriteString(tok.String())
+		case bool:
+			buf.WriteString(strconv.FormatBool(tok))
+		case nil:
+			buf.WriteString("null")
+		}
+
+		if len(stack) == 0 {
+			return buf.Bytes(), dec.InputOffset(), nil
+		}
+	}
+}
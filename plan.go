@@ -0,0 +1,149 @@
+package jparser
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Plan is meta compiled and validated once by Compile, for parsing many
+// documents. It is safe for concurrent use.
+type Plan struct {
+	*Parser
+}
+
+// Compile compiles meta into a Plan. Malformed paths are reported up front as
+// a *MetaError: bracket segments that aren't operators, tokens such as "@" not
+// ending a path after an iterating segment, tokens such as "#" or "&type" not
+// ending a path, and nodes addressed both as an array and as an object by
+// different paths.
+func Compile(meta []MetaData) (*Plan, error) {
+	return CompileWithOptions(meta, Options{})
+}
+
+func CompileWithOptions(meta []MetaData, opts Options) (*Plan, error) {
+	p, err := NewParser(meta, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	root := p.root
+	if p.arrayRoot != nil {
+		root = p.arrayRoot
+	}
+
+	if err := root.validate(""); err != nil {
+		return nil, restoreParamID(err)
+	}
+
+	return &Plan{p}, nil
+}
+
+// ValidateMeta checks meta for structural issues without any data: an empty
+// ParamID, a ParamID shared by several entries and the malformed paths
+// reported by Compile. It returns a *MetaError naming the first offending
+// entry.
+func ValidateMeta(meta []MetaData) error {
+	for _, m := range meta {
+		if m.ParamID == "" {
+			return &MetaError{m.ParamID, m.Path, "empty ParamID", nil}
+		}
+	}
+
+	_, err := CompileWithOptions(meta, Options{DuplicateParamID: DuplicateError})
+
+	return err
+}
+
+// Parse is ParseParams with the meta and options of the plan.
+func (p *Plan) Parse(data json.RawMessage) ([]RawMessageSet, error) {
+	return p.ParseParams(data)
+}
+
+// validate checks the groups of lvl and the levels below them. path is the
+// location of lvl in the meta paths.
+func (lvl *level) validate(path string) error {
+	var array, object *group
+
+	for _, g := range lvl.groups {
+		segmentPath := joinPath(path, g.segment)
+
+		if isValueToken(g.segment) {
+			for _, m := range g.meta {
+				if m.Path != "" {
+					return &MetaError{m.ParamID, segmentPath, "token " + g.segment +
+						" must end a path, escape it to address a key", nil}
+				}
+			}
+		}
+
+		switch g.container() {
+		case "array":
+			array = g
+		case "object":
+			if strings.HasPrefix(g.segment, "[") && quotedKeyEnd(g.segment) != len(g.segment) {
+				return &MetaError{g.meta[0].ParamID, segmentPath, "unknown bracket segment", nil}
+			}
+
+			if isToken(g.segment) {
+				return &MetaError{g.meta[0].ParamID, segmentPath, "token " + g.segment +
+					" must end a path after an iterating segment, escape it to address a key", nil}
+			}
+
+			object = g
+		}
+
+		if array != nil && object != nil {
+			return &MetaError{g.meta[0].ParamID, segmentPath, "node addressed both as an array by " +
+				joinPath(path, array.segment) + " and as an object by " + joinPath(path, object.segment), nil}
+		}
+
+		for _, next := range g.levels() {
+			if err := next.validate(segmentPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// isToken reports whether segment is one of the tokens following an
+// iterating segment, see splitMeta.
+func isToken(segment string) bool {
+	switch segment {
+	case "@", "%", "#1", "$":
+		return true
+	}
+
+	return false
+}
+
+// container returns the JSON type g expects of the node it applies to, or ""
+// if it accepts any.
+func (g *group) container() string {
+	switch {
+	case g.segment == "&now" || g.segment == "**" || g.segment == "^" || isValueToken(g.segment):
+		return ""
+	case g.segment == "[]" || g.segment == "[*]" || g.element != nil || g.window != nil || g.filter != nil:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// levels returns the compiled levels below g.
+func (g *group) levels() []*level {
+	var res []*level
+
+	for _, lvl := range []*level{g.next, g.base} {
+		if lvl != nil {
+			res = append(res, lvl)
+		}
+	}
+
+	for _, a := range g.aggregates {
+		res = append(res, a.next)
+	}
+
+	return res
+}
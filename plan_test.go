@@ -0,0 +1,151 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestPlanParse(t *testing.T) {
+	plan, err := jparser.Compile(sharedPrefixMeta)
+	if err != nil {
+		t.Fatalf("Compile() got error = \"%v\", expected nil", err)
+	}
+
+	for _, data := range []json.RawMessage{oneElementInArrayJSON, multipleElementsInArrayJSON} {
+		expected, err := jparser.ParseParams(data, sharedPrefixMeta)
+		if err != nil {
+			t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+		}
+
+		res, err := plan.Parse(data)
+		if err != nil {
+			t.Fatalf("Parse() got error = \"%v\", expected nil", err)
+		}
+
+		if !reflect.DeepEqual(res, expected) {
+			t.Errorf("Parse() got result = %v, expected %v", res, expected)
+		}
+	}
+}
+
+func TestCompileMalformedPaths(t *testing.T) {
+	testTable := []struct {
+		name         string
+		meta         []jparser.MetaData
+		expectedPath string
+	}{
+		{
+			name: "array and object",
+			meta: []jparser.MetaData{
+				{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+				{Path: "[].UL.branches.kpp", ParamID: "branch_kpp"},
+			},
+			expectedPath: "[].UL.branches.kpp",
+		},
+		{
+			name: "array and wildcard",
+			meta: []jparser.MetaData{
+				{Path: "contactPhones.*.count", ParamID: "count"},
+				{Path: "contactPhones.[0]", ParamID: "first"},
+			},
+			expectedPath: "contactPhones.[0]",
+		},
+		{
+			name:         "unknown bracket segment",
+			meta:         []jparser.MetaData{{Path: "[].UL.[kpp]", ParamID: "kpp"}},
+			expectedPath: "[].UL.[kpp]",
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			plan, err := jparser.Compile(testCase.meta)
+
+			var metaErr *jparser.MetaError
+			if !errors.As(err, &metaErr) || metaErr.Path != testCase.expectedPath || plan != nil {
+				t.Errorf("Compile() got error = \"%v\", expected *MetaError for %s", err, testCase.expectedPath)
+			}
+		})
+	}
+
+	if _, err := jparser.Compile([]jparser.MetaData{
+		{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+		{Path: "[].UL.branches.[0].kpp", ParamID: "first_kpp"},
+		{Path: "[].UL.branches.[?kpp=667101001]", ParamID: "branch"},
+		{Path: `[].UL.["branches"]`, ParamID: "branches"},
+		{Path: "[].UL.**.kpp", ParamID: "any_kpp"},
+		{Path: "[].UL.&now", ParamID: "now"},
+	}); err != nil {
+		t.Errorf("Compile() got error = \"%v\", expected nil", err)
+	}
+}
+
+func TestValidateMeta(t *testing.T) {
+	testTable := []struct {
+		name            string
+		meta            []jparser.MetaData
+		expectedParamID string
+		expectedPath    string
+	}{
+		{
+			name: "empty ParamID",
+			meta: []jparser.MetaData{
+				{Path: "[].inn", ParamID: "inn"},
+				{Path: "[].ogrn"},
+			},
+			expectedPath: "[].ogrn",
+		},
+		{
+			name: "duplicate ParamID",
+			meta: []jparser.MetaData{
+				{Path: "[].inn", ParamID: "inn"},
+				{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+				{Path: "[].UL.kpp", ParamID: "kpp"},
+			},
+			expectedParamID: "kpp",
+			expectedPath:    "[].UL.kpp",
+		},
+		{
+			name: "token in the middle of a path",
+			meta: []jparser.MetaData{
+				{Path: "[].UL.branches.[].#.kpp", ParamID: "kpp"},
+			},
+			expectedParamID: "kpp",
+			expectedPath:    "[].UL.branches.[].#",
+		},
+		{
+			name: "token after a key",
+			meta: []jparser.MetaData{
+				{Path: "[].UL.@", ParamID: "index"},
+			},
+			expectedParamID: "index",
+			expectedPath:    "[].UL.@",
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := jparser.ValidateMeta(testCase.meta)
+
+			var metaErr *jparser.MetaError
+			if !errors.As(err, &metaErr) || metaErr.ParamID != testCase.expectedParamID ||
+				metaErr.Path != testCase.expectedPath {
+				t.Errorf("ValidateMeta() got error = \"%v\", expected *MetaError for %q at %s",
+					err, testCase.expectedParamID, testCase.expectedPath)
+			}
+		})
+	}
+
+	if err := jparser.ValidateMeta(append([]jparser.MetaData{
+		{Path: "[].UL.branches.[].@", ParamID: "branch"},
+		{Path: "[].UL.branches.[].#", ParamID: "branches"},
+		{Path: `[].UL.\#`, ParamID: "hash"},
+		{Path: "[].UL.legalName.full.#", ParamID: "name_len"},
+	}, sharedPrefixMeta...)); err != nil {
+		t.Errorf("ValidateMeta() got error = \"%v\", expected nil", err)
+	}
+}
@@ -0,0 +1,183 @@
+package jparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PointerMeta addresses the value bound under ParamID by an RFC 6901 JSON
+// Pointer, see ParseParamsPointer.
+type PointerMeta struct {
+	Pointer string
+	ParamID string
+}
+
+// ParseParamsPointer is ParseParams with paths written as JSON Pointers, e.g.
+// "/UL/branches/0/kpp". A "-" token iterates every element of an array like
+// "[]", and a token of digits without leading zeros selects an element like
+// "[N]", unless it applies to an object in data, where it is a key. A
+// malformed pointer fails with a *MetaError.
+func ParseParamsPointer(data json.RawMessage, meta []PointerMeta) ([]RawMessageSet, error) {
+	pathMeta := make([]MetaData, len(meta))
+
+	for i, m := range meta {
+		path, err := pointerPath(m.Pointer, data)
+		if err != nil {
+			return nil, &MetaError{m.ParamID, m.Pointer, err.Error(), err}
+		}
+
+		pathMeta[i] = MetaData{Path: path, ParamID: m.ParamID}
+	}
+
+	return ParseParams(data, pathMeta)
+}
+
+// pointerPath translates a JSON Pointer to a meta path, quoting the keys
+// that don't read as plain keys. Tokens of digits are translated to keys or
+// indices by the first of the values of data they apply to that is an object
+// or an array.
+func pointerPath(pointer string, data json.RawMessage) (string, error) {
+	if pointer == "" {
+		return "", nil
+	}
+
+	if pointer[0] != '/' {
+		return "", fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	segments := make([]string, len(tokens))
+	values := []json.RawMessage{data}
+
+	for i, token := range tokens {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+
+		switch {
+		case token == "-":
+			segments[i] = "[]"
+		case isArrayIndex(token) && !appliesToObject(values):
+			segments[i] = "[" + token + "]"
+		default:
+			segments[i] = keySegment(token)
+		}
+
+		values = pointerStep(values, token)
+	}
+
+	return strings.Join(segments, "."), nil
+}
+
+// appliesToObject reports whether the first of values that is an object or
+// an array is an object.
+func appliesToObject(values []json.RawMessage) bool {
+	for _, value := range values {
+		switch jsonType(value) {
+		case "object":
+			return true
+		case "array":
+			return false
+		}
+	}
+
+	return false
+}
+
+// pointerStep returns the values the JSON Pointer token addresses in values:
+// the values under the key token of objects, and the elements of arrays
+// selected by an index or "-".
+func pointerStep(values []json.RawMessage, token string) []json.RawMessage {
+	var res []json.RawMessage
+
+	for _, value := range values {
+		switch jsonType(value) {
+		case "object":
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal(value, &object); err == nil {
+				if next, ok := object[token]; ok {
+					res = append(res, next)
+				}
+			}
+		case "array":
+			var array []json.RawMessage
+			if err := json.Unmarshal(value, &array); err != nil {
+				continue
+			}
+
+			if token == "-" {
+				res = append(res, array...)
+			} else if i, err := strconv.Atoi(token); err == nil && isArrayIndex(token) && i < len(array) {
+				res = append(res, array[i])
+			}
+		}
+	}
+
+	return res
+}
+
+// isArrayIndex reports whether token is an array index of a JSON Pointer:
+// "0" or digits not starting with "0".
+func isArrayIndex(token string) bool {
+	if token == "" || (token[0] == '0' && len(token) > 1) {
+		return false
+	}
+
+	for i := 0; i < len(token); i++ {
+		if token[i] < '0' || token[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// resolvePointer returns the value addressed by an RFC 6901 JSON Pointer in
+// doc. A leading "#" (URI fragment form) is accepted.
+func resolvePointer(doc json.RawMessage, pointer string) (json.RawMessage, error) {
+	pointer = strings.TrimPrefix(pointer, "#")
+	if pointer == "" {
+		return doc, nil
+	}
+
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+
+	value := doc
+
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+
+		switch jsonType(value) {
+		case "object":
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal(value, &object); err != nil {
+				return nil, err
+			}
+
+			next, ok := object[token]
+			if !ok {
+				return nil, fmt.Errorf("JSON pointer %q: key %q not found", pointer, token)
+			}
+
+			value = next
+		case "array":
+			var array []json.RawMessage
+			if err := json.Unmarshal(value, &array); err != nil {
+				return nil, err
+			}
+
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(array) {
+				return nil, fmt.Errorf("JSON pointer %q: index %q out of range", pointer, token)
+			}
+
+			value = array[i]
+		default:
+			return nil, fmt.Errorf("JSON pointer %q: %q is not a container", pointer, token)
+		}
+	}
+
+	return value, nil
+}
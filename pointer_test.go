@@ -0,0 +1,114 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestParseParamsPointer(t *testing.T) {
+	testTable := []struct {
+		name    string
+		data    json.RawMessage
+		meta    []jparser.MetaData
+		pointer []jparser.PointerMeta
+	}{
+		{
+			name: "Keys",
+			data: oneObjectInJSON,
+			meta: []jparser.MetaData{
+				{Path: "inn", ParamID: "inn"},
+				{Path: "IP.status.statusString", ParamID: "status"},
+			},
+			pointer: []jparser.PointerMeta{
+				{Pointer: "/inn", ParamID: "inn"},
+				{Pointer: "/IP/status/statusString", ParamID: "status"},
+			},
+		},
+		{
+			name: "Array elements",
+			data: oneElementInArrayJSON,
+			meta: []jparser.MetaData{
+				{Path: "[].inn", ParamID: "inn"},
+				{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+				{Path: "[0].UL.branches.[1].kpp", ParamID: "second_branch"},
+			},
+			pointer: []jparser.PointerMeta{
+				{Pointer: "/-/inn", ParamID: "inn"},
+				{Pointer: "/-/UL/branches/-/kpp", ParamID: "kpp"},
+				{Pointer: "/0/UL/branches/1/kpp", ParamID: "second_branch"},
+			},
+		},
+		{
+			name: "Escaped keys",
+			data: json.RawMessage(`{"activities": {"43.21": "Производство электромонтажных работ"},
+				"a/b": {"~1": "slash", "@": "at"}}`),
+			meta: []jparser.MetaData{
+				{Path: `activities.43\.21`, ParamID: "okved"},
+				{Path: `["a/b"].["~1"]`, ParamID: "tilde"},
+				{Path: `["a/b"].\@`, ParamID: "at"},
+			},
+			pointer: []jparser.PointerMeta{
+				{Pointer: "/activities/43.21", ParamID: "okved"},
+				{Pointer: "/a~1b/~01", ParamID: "tilde"},
+				{Pointer: "/a~1b/@", ParamID: "at"},
+			},
+		},
+		{
+			name: "Digit keys of objects",
+			data: json.RawMessage(`{"a": {"0": "key"}, "b": ["element"], "c": [{"1": "key in element"}]}`),
+			meta: []jparser.MetaData{
+				{Path: "a.0", ParamID: "key"},
+				{Path: "b.[0]", ParamID: "element"},
+				{Path: "c.[0].1", ParamID: "key_in_element"},
+			},
+			pointer: []jparser.PointerMeta{
+				{Pointer: "/a/0", ParamID: "key"},
+				{Pointer: "/b/0", ParamID: "element"},
+				{Pointer: "/c/0/1", ParamID: "key_in_element"},
+			},
+		},
+		{
+			name: "Parent token as a key",
+			data: json.RawMessage(`{"a": {"^": "caret", "b": "parent"}}`),
+			meta: []jparser.MetaData{
+				{Path: `a.["^"]`, ParamID: "caret"},
+			},
+			pointer: []jparser.PointerMeta{
+				{Pointer: "/a/^", ParamID: "caret"},
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			expectedRes, err := jparser.ParseParams(testCase.data, testCase.meta)
+			if err != nil {
+				t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+			}
+
+			result, err := jparser.ParseParamsPointer(testCase.data, testCase.pointer)
+			if err != nil {
+				t.Fatalf("ParseParamsPointer() got error = \"%v\", expected nil", err)
+			}
+
+			if !reflect.DeepEqual(result, expectedRes) {
+				t.Errorf("ParseParamsPointer() got result = %s, expectedRes = %s", result, expectedRes)
+			}
+
+			if len(result) == 0 || len(result[0]) != len(testCase.pointer) {
+				t.Errorf("ParseParamsPointer() got result = %s, expected every param bound", result)
+			}
+		})
+	}
+
+	var metaErr *jparser.MetaError
+	if _, err := jparser.ParseParamsPointer(oneObjectInJSON, []jparser.PointerMeta{
+		{Pointer: "inn", ParamID: "inn"},
+	}); !errors.As(err, &metaErr) || metaErr.ParamID != "inn" {
+		t.Errorf("ParseParamsPointer() got error = \"%v\", expected *MetaError for inn", err)
+	}
+}
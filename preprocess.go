@@ -0,0 +1,101 @@
+package jparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Step rewrites a document before it is parsed, see Options.Preprocess.
+type Step func(json.RawMessage) (json.RawMessage, error)
+
+func (p *Parser) preprocess(data json.RawMessage) (json.RawMessage, error) {
+	for i, step := range p.opts.Preprocess {
+		var err error
+
+		if data, err = step(data); err != nil {
+			return nil, fmt.Errorf("preprocess step %d: %w", i, err)
+		}
+	}
+
+	return data, nil
+}
+
+// RenameKey returns a Step renaming the key from to to in every object of the
+// document. An existing key to is overwritten.
+func RenameKey(from, to string) Step {
+	return func(data json.RawMessage) (json.RawMessage, error) {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+
+		return marshalRaw(renameKey(v, from, to))
+	}
+}
+
+// marshalRaw is json.Marshal without escaping HTML characters in strings, so
+// that values such as "A&B" keep their bytes.
+func marshalRaw(v interface{}) (json.RawMessage, error) {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func renameKey(v interface{}, from, to string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, value := range v {
+			v[k] = renameKey(value, from, to)
+		}
+
+		if value, ok := v[from]; ok {
+			delete(v, from)
+			v[to] = value
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = renameKey(value, from, to)
+		}
+	}
+
+	return v
+}
+
+// UnwrapPrefix returns a Step replacing the document with the value at the
+// key path prefix, e.g. "response.data" for an envelope. A missing prefix is
+// an error.
+func UnwrapPrefix(prefix string) Step {
+	return func(data json.RawMessage) (json.RawMessage, error) {
+		for rest := prefix; rest != ""; {
+			var key string
+
+			key, rest = splitPath(rest)
+			key = unescapeKey(key)
+
+			var object RawMessageSet
+			if err := json.Unmarshal(data, &object); err != nil {
+				return nil, err
+			}
+
+			value, ok := object[key]
+			if !ok {
+				return nil, fmt.Errorf("unwrap %s: key %s not found", prefix, key)
+			}
+
+			data = value
+		}
+
+		return data, nil
+	}
+}
@@ -0,0 +1,62 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestParseParamsPreprocess(t *testing.T) {
+	data := json.RawMessage(`{"response": {"data": [
+		{"INN": "6663003127", "UL": {"branches": [{"KPP": "771543001"}]}},
+		{"inn": "7736207543"}
+	]}}`)
+
+	meta := []jparser.MetaData{
+		{Path: "[].inn", ParamID: "inn"},
+		{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+	}
+
+	opts := jparser.Options{
+		Preprocess: []jparser.Step{
+			jparser.UnwrapPrefix("response.data"),
+			jparser.RenameKey("INN", "inn"),
+			jparser.RenameKey("KPP", "kpp"),
+		},
+	}
+
+	result, err := jparser.ParseParamsWithOptions(data, meta, opts)
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	expected := []jparser.RawMessageSet{
+		{"inn": json.RawMessage(`"6663003127"`), "kpp": json.RawMessage(`"771543001"`)},
+		{"inn": json.RawMessage(`"7736207543"`)},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("ParseParamsWithOptions() got result = %v, expected %v", result, expected)
+	}
+
+	opts.Preprocess = []jparser.Step{jparser.UnwrapPrefix("response.items")}
+	if _, err := jparser.ParseParamsWithOptions(data, meta, opts); err == nil {
+		t.Errorf("ParseParamsWithOptions() got error = nil for a missing prefix, expected error")
+	}
+}
+
+func TestRenameKeyKeepsHTMLCharacters(t *testing.T) {
+	data := json.RawMessage(`{"NAME": "A&B <Co>"}`)
+
+	result, err := jparser.ParseParamsWithOptions(data, []jparser.MetaData{{Path: "name", ParamID: "name"}},
+		jparser.Options{Preprocess: []jparser.Step{jparser.RenameKey("NAME", "name")}})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	expected := []jparser.RawMessageSet{{"name": json.RawMessage(`"A&B <Co>"`)}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("ParseParamsWithOptions() got result = %s, expected %s", result, expected)
+	}
+}
@@ -0,0 +1,225 @@
+package jparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"unicode/utf8"
+)
+
+// SchemaError reports a bound value violating the JSON Schema of its param.
+type SchemaError struct {
+	ParamID string
+	Value   json.RawMessage
+	Reason  string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("error: value %s violates schema: %s, param_id: %s", e.Value, e.Reason, e.ParamID)
+}
+
+// schema is the supported subset of JSON Schema.
+type schema struct {
+	Types     []string
+	Enum      []json.RawMessage
+	Pattern   *regexp.Regexp
+	Minimum   *big.Rat
+	Maximum   *big.Rat
+	MinLength *int
+	MaxLength *int
+	// codec decodes the schema and the validated strings.
+	codec JSONCodec
+}
+
+func compileSchema(codec JSONCodec, data json.RawMessage) (*schema, error) {
+	var raw struct {
+		Type      json.RawMessage   `json:"type"`
+		Enum      []json.RawMessage `json:"enum"`
+		Pattern   *string           `json:"pattern"`
+		Minimum   json.RawMessage   `json:"minimum"`
+		Maximum   json.RawMessage   `json:"maximum"`
+		MinLength *int              `json:"minLength"`
+		MaxLength *int              `json:"maxLength"`
+	}
+
+	if err := codec.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	s := &schema{Enum: raw.Enum, MinLength: raw.MinLength, MaxLength: raw.MaxLength, codec: codec}
+
+	if len(raw.Type) > 0 {
+		if err := codec.Unmarshal(raw.Type, &s.Types); err != nil {
+			var t string
+			if err := codec.Unmarshal(raw.Type, &t); err != nil {
+				return nil, fmt.Errorf("invalid schema type %s", raw.Type)
+			}
+
+			s.Types = []string{t}
+		}
+	}
+
+	if raw.Pattern != nil {
+		pattern, err := regexp.Compile(*raw.Pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Pattern = pattern
+	}
+
+	for _, bound := range []struct {
+		raw json.RawMessage
+		dst **big.Rat
+	}{{raw.Minimum, &s.Minimum}, {raw.Maximum, &s.Maximum}} {
+		if len(bound.raw) == 0 {
+			continue
+		}
+
+		value, ok := parseNumber(bound.raw)
+		if !ok {
+			return nil, fmt.Errorf("invalid schema bound %s", bound.raw)
+		}
+
+		*bound.dst = value
+	}
+
+	return s, nil
+}
+
+// validate returns the reason value violates s, or "" if it doesn't.
+// nolint:gocognit,cyclop
+func (s *schema) validate(value json.RawMessage) string {
+	valueType := jsonType(value)
+
+	if len(s.Types) > 0 && !s.hasType(value, valueType) {
+		return fmt.Sprintf("type %s is not one of %v", valueType, s.Types)
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+
+		for _, e := range s.Enum {
+			if equalJSON(e, value) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return "value is not in enum"
+		}
+	}
+
+	if valueType == "string" {
+		var str string
+		if err := s.codec.Unmarshal(value, &str); err != nil {
+			return err.Error()
+		}
+
+		length := utf8.RuneCountInString(str)
+
+		if s.MinLength != nil && length < *s.MinLength {
+			return fmt.Sprintf("length %d is less than %d", length, *s.MinLength)
+		}
+
+		if s.MaxLength != nil && length > *s.MaxLength {
+			return fmt.Sprintf("length %d is greater than %d", length, *s.MaxLength)
+		}
+
+		if s.Pattern != nil && !s.Pattern.MatchString(str) {
+			return fmt.Sprintf("does not match pattern %s", s.Pattern)
+		}
+	}
+
+	if number, ok := parseNumber(value); ok {
+		if s.Minimum != nil && number.Cmp(s.Minimum) < 0 {
+			return fmt.Sprintf("less than minimum %s", s.Minimum.RatString())
+		}
+
+		if s.Maximum != nil && number.Cmp(s.Maximum) > 0 {
+			return fmt.Sprintf("greater than maximum %s", s.Maximum.RatString())
+		}
+	}
+
+	return ""
+}
+
+func (s *schema) hasType(value json.RawMessage, valueType string) bool {
+	for _, t := range s.Types {
+		if t == valueType {
+			return true
+		}
+
+		if t == "integer" && valueType == "number" {
+			if number, ok := parseNumber(value); ok && number.IsInt() {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// resolveSchemaRefs returns meta with the SchemaRef of the entries without a
+// Schema replaced by the schema it points to in opts.SchemaDocument.
+func resolveSchemaRefs(meta []MetaData, opts Options) ([]MetaData, error) {
+	var res []MetaData
+
+	for i, m := range meta {
+		if len(m.Schema) > 0 || m.SchemaRef == "" {
+			continue
+		}
+
+		ref, err := resolvePointer(opts.SchemaDocument, m.SchemaRef)
+		if err == nil {
+			_, err = compileSchema((&parser{opts: opts}).codec(), ref)
+		}
+
+		if err != nil {
+			return nil, &MetaError{m.ParamID, m.Path, "schema ref " + m.SchemaRef + ": " + err.Error(), err}
+		}
+
+		if res == nil {
+			res = append([]MetaData(nil), meta...)
+		}
+
+		res[i].Schema, res[i].SchemaRef = ref, ""
+	}
+
+	if res == nil {
+		return meta, nil
+	}
+
+	return res, nil
+}
+
+func (p *parser) validateSchema(value json.RawMessage, m MetaData) error {
+	data := m.Schema
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	s, ok := p.schemas[string(data)]
+	if !ok {
+		compiled, err := compileSchema(p.codec(), data)
+		if err != nil {
+			return &UnmarshalError{err: err, paramID: m.ParamID}
+		}
+
+		if p.schemas == nil {
+			p.schemas = make(map[string]*schema)
+		}
+
+		p.schemas[string(data)] = compiled
+		s = compiled
+	}
+
+	if reason := s.validate(value); reason != "" {
+		return &SchemaError{m.ParamID, bytes.TrimSpace(value), reason}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestParseParamsSchema(t *testing.T) {
+	schemaDocument := json.RawMessage(`{
+		"definitions": {
+			"kpp": {"type": "string", "pattern": "^[0-9]{9}$"},
+			"count": {"type": "integer", "minimum": 0, "maximum": 10}
+		}
+	}`)
+
+	testTable := []struct {
+		name        string
+		meta        jparser.MetaData
+		expectedErr bool
+	}{
+		{
+			name: "Inline type",
+			meta: jparser.MetaData{Path: "[].inn", ParamID: "inn", Schema: json.RawMessage(`{"type": "string"}`)},
+		},
+		{
+			name: "Inline type mismatch",
+			meta: jparser.MetaData{
+				Path: "[].inn", ParamID: "inn", Schema: json.RawMessage(`{"type": ["number", "null"]}`),
+			},
+			expectedErr: true,
+		},
+		{
+			name: "Inline pattern",
+			meta: jparser.MetaData{
+				Path: "[].UL.branches.[].date", ParamID: "date", Schema: json.RawMessage(`{"pattern": "^\\d{4}-\\d{2}-\\d{2}$"}`),
+			},
+		},
+		{
+			name: "Inline pattern mismatch",
+			meta: jparser.MetaData{
+				Path: "[].UL.branches.[].date", ParamID: "date", Schema: json.RawMessage(`{"pattern": "^201[0-9]"}`),
+			},
+			expectedErr: true,
+		},
+		{
+			name: "Enum",
+			meta: jparser.MetaData{
+				Path: "[].UL.status.statusString", ParamID: "status", Schema: json.RawMessage(`{"enum": ["Действующее"]}`),
+			},
+		},
+		{
+			name: "Referenced pattern",
+			meta: jparser.MetaData{Path: "[].UL.branches.[].kpp", ParamID: "kpp", SchemaRef: "#/definitions/kpp"},
+		},
+		{
+			name:        "Referenced maximum",
+			meta:        jparser.MetaData{Path: "[].contactPhones.count", ParamID: "count", SchemaRef: "#/definitions/count"},
+			expectedErr: true,
+		},
+	}
+
+	for _, codec := range testCodecs {
+		for _, test := range testTable {
+			t.Run(codec.name+"/"+test.name, func(t *testing.T) {
+				_, err := jparser.ParseParamsWithOptions(oneElementInArrayJSON, []jparser.MetaData{test.meta},
+					jparser.Options{SchemaDocument: schemaDocument, Codec: codec.codec})
+
+				if !test.expectedErr {
+					if err != nil {
+						t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+					}
+
+					return
+				}
+
+				var schemaErr *jparser.SchemaError
+				if !errors.As(err, &schemaErr) || schemaErr.ParamID != test.meta.ParamID {
+					t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected *SchemaError", err)
+				}
+			})
+		}
+	}
+}
+
+func TestNewParserSchemaRef(t *testing.T) {
+	schemaDocument := json.RawMessage(`{"definitions": {"kpp": {"pattern": "["}, "inn": {"type": "string"}}}`)
+
+	testTable := []struct {
+		name      string
+		schemaRef string
+		valid     bool
+	}{
+		{name: "Resolved", schemaRef: "#/definitions/inn", valid: true},
+		{name: "Missing", schemaRef: "#/definitions/ogrn"},
+		{name: "Invalid schema", schemaRef: "#/definitions/kpp"},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			meta := []jparser.MetaData{{Path: "[].inn", ParamID: "inn", SchemaRef: test.schemaRef}}
+
+			_, err := jparser.NewParser(meta, jparser.Options{SchemaDocument: schemaDocument})
+			if test.valid {
+				if err != nil {
+					t.Errorf("NewParser() got error = \"%v\", expected nil", err)
+				}
+
+				return
+			}
+
+			var metaErr *jparser.MetaError
+			if !errors.As(err, &metaErr) || metaErr.ParamID != "inn" || metaErr.Path != "[].inn" {
+				t.Errorf("NewParser() got error = \"%v\", expected *MetaError for inn", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package jparser
+
+import (
+	"strings"
+)
+
+// applySeparator rewrites the paths of meta written with Options.Separator to
+// the default "." syntax.
+func applySeparator(meta []MetaData, separator string) []MetaData {
+	if separator == "" || separator == "." {
+		return meta
+	}
+
+	res := make([]MetaData, len(meta))
+
+	for i, m := range meta {
+		res[i] = m
+		res[i].Path = separatedPath(m.Path, separator)
+	}
+
+	return res
+}
+
+func separatedAliases(aliases map[string]string, separator string) map[string]string {
+	if separator == "" || separator == "." || len(aliases) == 0 {
+		return aliases
+	}
+
+	res := make(map[string]string, len(aliases))
+	for name, path := range aliases {
+		res[name] = separatedPath(path, separator)
+	}
+
+	return res
+}
+
+// separatedPath splits path on separator and joins the segments with ".",
+// escaping the dots of key segments.
+func separatedPath(path, separator string) string {
+	if path == "" {
+		return ""
+	}
+
+	segments := strings.Split(path, separator)
+
+	for i, segment := range segments {
+		// The dots of filters and quoted keys are kept by splitPath.
+		if !strings.HasPrefix(segment, "[?") && quotedKeyEnd(segment) != len(segment) {
+			segments[i] = strings.ReplaceAll(segment, ".", `\.`)
+		}
+	}
+
+	return strings.Join(segments, ".")
+}
@@ -0,0 +1,102 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestParseParamsSeparator(t *testing.T) {
+	testTable := []struct {
+		name string
+		data json.RawMessage
+		meta []jparser.MetaData
+	}{
+		{
+			name: "Shared prefix",
+			data: oneElementInArrayJSON,
+			meta: sharedPrefixMeta,
+		},
+		{
+			name: "Tokens",
+			data: oneElementInArrayJSON,
+			meta: []jparser.MetaData{
+				{Path: "[].inn", ParamID: "inn"},
+				{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+				{Path: "[].UL.branches.[].@", ParamID: "branch"},
+				{Path: "[].UL.branches.[].#", ParamID: "branches"},
+				{Path: "[].UL.branches.[?parsedAddressRF.regionCode=77].kpp", ParamID: "moscow_kpp"},
+			},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			expectedRes, err := jparser.ParseParams(test.data, test.meta)
+			if err != nil {
+				t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+			}
+
+			meta := make([]jparser.MetaData, len(test.meta))
+			for i, m := range test.meta {
+				meta[i] = m
+				meta[i].Path = slashed(m.Path)
+			}
+
+			result, err := jparser.ParseParamsWithOptions(test.data, meta, jparser.Options{Separator: "/"})
+			if err != nil {
+				t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+			}
+
+			if !reflect.DeepEqual(result, expectedRes) {
+				t.Errorf("ParseParamsWithOptions() got result = %v, expectedRes = %v", result, expectedRes)
+			}
+		})
+	}
+}
+
+func TestParseParamsSeparatorDottedKeys(t *testing.T) {
+	data := json.RawMessage(`{"activities": {"43.21": {"name": "Производство электромонтажных работ"}}}`)
+
+	opts := jparser.Options{Separator: "/"}
+	opts.DefineAlias("okved", "activities/43.21")
+
+	result, err := jparser.ParseParamsWithOptions(data, []jparser.MetaData{
+		{Path: "activities/43.21/name", ParamID: "name"},
+		{Path: "$okved/name", ParamID: "alias_name"},
+	}, opts)
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	expectedRes := []jparser.RawMessageSet{{
+		"name":       json.RawMessage(`"Производство электромонтажных работ"`),
+		"alias_name": json.RawMessage(`"Производство электромонтажных работ"`),
+	}}
+
+	if !reflect.DeepEqual(result, expectedRes) {
+		t.Errorf("ParseParamsWithOptions() got result = %v, expectedRes = %v", result, expectedRes)
+	}
+}
+
+// slashed replaces the dots separating the segments of path by slashes,
+// leaving the dots inside brackets.
+func slashed(path string) string {
+	res := []byte(path)
+	depth := 0
+
+	for i, c := range res {
+		switch {
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '.' && depth == 0:
+			res[i] = '/'
+		}
+	}
+
+	return string(res)
+}
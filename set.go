@@ -0,0 +1,351 @@
+package jparser
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrMultipleSets = errors.New("more than one result set")
+
+// ConflictError reports a param bound to different values by two sources.
+type ConflictError struct {
+	ParamID  string
+	Existing json.RawMessage
+	New      json.RawMessage
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("error: conflicting values %s and %s, param_id: %s", e.Existing, e.New, e.ParamID)
+}
+
+// MergeFrom parses data, which must produce a single result set, and merges
+// its params into s. Params already present in s must have an equal value,
+// otherwise a *ConflictError is returned and s is left unchanged.
+func (s RawMessageSet) MergeFrom(data json.RawMessage, meta []MetaData) error {
+	set, err := ParseParamsSingle(data, meta)
+	if err != nil {
+		return err
+	}
+
+	for paramID, value := range set {
+		if existing, ok := s[paramID]; ok && !equalJSON(existing, value) {
+			return &ConflictError{paramID, existing, value}
+		}
+	}
+
+	for paramID, value := range set {
+		s[paramID] = value
+	}
+
+	return nil
+}
+
+// ParseParamsSingle is ParseParams for meta producing a single result set,
+// such as meta not iterating arrays. It fails with ErrMultipleSets if an
+// iteration yields more than one set.
+func ParseParamsSingle(data json.RawMessage, meta []MetaData) (RawMessageSet, error) {
+	res, err := ParseParams(data, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(res) {
+	case 0:
+		return RawMessageSet{}, nil
+	case 1:
+		return res[0], nil
+	default:
+		return nil, ErrMultipleSets
+	}
+}
+
+// ParseParamsJSON is ParseParams returning the result sets as a JSON array of
+// objects with sorted keys. Values are copied as extracted, only compacted, so
+// that numbers keep their exact representation.
+func ParseParamsJSON(data json.RawMessage, meta []MetaData) (json.RawMessage, error) {
+	res, err := ParseParams(data, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeSets(res)
+}
+
+// encodeSets assembles the JSON array of sets without decoding their values.
+func encodeSets(sets []RawMessageSet) (json.RawMessage, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('[')
+
+	for i, set := range sets {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		paramIDs := make([]string, 0, len(set))
+		for paramID := range set {
+			paramIDs = append(paramIDs, paramID)
+		}
+
+		sort.Strings(paramIDs)
+
+		buf.WriteByte('{')
+
+		for j, paramID := range paramIDs {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+
+			if err := writeParam(&buf, paramID, set[paramID]); err != nil {
+				return nil, err
+			}
+		}
+
+		buf.WriteByte('}')
+	}
+
+	buf.WriteByte(']')
+
+	return buf.Bytes(), nil
+}
+
+// writeParam writes the object member of paramID, with value compacted.
+func writeParam(buf *bytes.Buffer, paramID string, value json.RawMessage) error {
+	key, _ := json.Marshal(paramID) // nolint:errchkjson // strings always marshal
+
+	buf.Write(key)
+	buf.WriteByte(':')
+
+	if err := json.Compact(buf, value); err != nil {
+		return &UnmarshalError{err: err, paramID: paramID}
+	}
+
+	return nil
+}
+
+// Param is a value bound under a ParamID.
+type Param struct {
+	ParamID string
+	Value   json.RawMessage
+}
+
+// OrderedSet is a result set with its params in meta order, see
+// ParseParamsOrdered.
+type OrderedSet []Param
+
+// MarshalJSON encodes s as an object with the params in order.
+func (s OrderedSet) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for i, param := range s {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		if err := writeParam(&buf, param.ParamID, param.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// ParseParamsOrdered is ParseParams returning the params of every set in the
+// order of meta, a ParamID shared by several entries at its first one. Params
+// not named by meta, such as expanded templates and companion values, follow
+// in sorted order.
+func ParseParamsOrdered(data json.RawMessage, meta []MetaData) ([]OrderedSet, error) {
+	res, err := ParseParams(data, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	order := make([]string, 0, len(meta))
+	declared := make(map[string]bool, len(meta))
+
+	for _, m := range meta {
+		if !declared[m.ParamID] {
+			declared[m.ParamID] = true
+			order = append(order, m.ParamID)
+		}
+	}
+
+	ordered := make([]OrderedSet, len(res))
+
+	for i, set := range res {
+		params := make(OrderedSet, 0, len(set))
+
+		for _, paramID := range order {
+			if value, ok := set[paramID]; ok {
+				params = append(params, Param{paramID, value})
+			}
+		}
+
+		var rest []string
+
+		for paramID := range set {
+			if !declared[paramID] {
+				rest = append(rest, paramID)
+			}
+		}
+
+		sort.Strings(rest)
+
+		for _, paramID := range rest {
+			params = append(params, Param{paramID, set[paramID]})
+		}
+
+		ordered[i] = params
+	}
+
+	return ordered, nil
+}
+
+// Hash returns the hex SHA-256 of the canonical JSON of s, with sorted keys
+// and values stripped of insignificant whitespace, so that sets holding equal
+// values hash equally. Invalid values are hashed as they are.
+func (s RawMessageSet) Hash() string {
+	canonical, err := canonicalSet(s)
+	if err != nil {
+		paramIDs := make([]string, 0, len(s))
+		for paramID := range s {
+			paramIDs = append(paramIDs, paramID)
+		}
+
+		sort.Strings(paramIDs)
+
+		var buf bytes.Buffer
+		for _, paramID := range paramIDs {
+			fmt.Fprintf(&buf, "%q:%q,", paramID, []byte(s[paramID]))
+		}
+
+		canonical = buf.String()
+	}
+
+	sum := sha256.Sum256([]byte(canonical))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// equalJSON reports whether a and b hold the same JSON value, ignoring
+// insignificant whitespace and object key order.
+func equalJSON(a, b json.RawMessage) bool {
+	canonicalA, errA := canonicalJSON(a)
+	canonicalB, errB := canonicalJSON(b)
+
+	if errA != nil || errB != nil {
+		return bytes.Equal(a, b)
+	}
+
+	return bytes.Equal(canonicalA, canonicalB)
+}
+
+// canonicalJSON re-encodes data without insignificant whitespace and with
+// sorted object keys, keeping numbers and HTML characters exactly as written.
+func canonicalJSON(data json.RawMessage) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return marshalRaw(v)
+}
+
+// distinct drops the sets of res returned before with Options.Distinct.
+func (p *parser) distinct(res []RawMessageSet) ([]RawMessageSet, error) {
+	if !p.opts.Distinct {
+		return res, nil
+	}
+
+	if p.seen == nil {
+		p.seen = make(map[string]bool, len(res))
+	}
+
+	kept := res[:0]
+
+	for _, set := range res {
+		key, err := canonicalSet(set)
+		if err != nil {
+			return nil, err
+		}
+
+		if !p.seen[key] {
+			p.seen[key] = true
+			kept = append(kept, set)
+		}
+	}
+
+	return kept, nil
+}
+
+// canonicalSet returns the canonical JSON of set as an object, equal for
+// sets holding equal values.
+func canonicalSet(set RawMessageSet) (string, error) {
+	data, err := json.Marshal(set)
+	if err != nil {
+		return "", err
+	}
+
+	canonical, err := canonicalJSON(data)
+	if err != nil {
+		return "", err
+	}
+
+	return string(canonical), nil
+}
+
+// nest groups the params of set whose ParamID holds sep into nested objects,
+// see Options.NestSeparator.
+func nest(set RawMessageSet, sep string) (RawMessageSet, error) {
+	groups := map[string]RawMessageSet{}
+	res := make(RawMessageSet, len(set))
+
+	for paramID, value := range set {
+		head, rest, ok := strings.Cut(paramID, sep)
+		if !ok || head == "" || rest == "" {
+			res[paramID] = value
+
+			continue
+		}
+
+		if groups[head] == nil {
+			groups[head] = RawMessageSet{}
+		}
+
+		groups[head][rest] = value
+	}
+
+	for head, group := range groups {
+		if _, ok := res[head]; ok {
+			for rest, value := range group {
+				res[head+sep+rest] = value
+			}
+
+			continue
+		}
+
+		nested, err := nest(group, sep)
+		if err != nil {
+			return nil, err
+		}
+
+		if res[head], err = marshalRaw(nested); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
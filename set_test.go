@@ -0,0 +1,309 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestRawMessageSetMergeFrom(t *testing.T) {
+	set := jparser.RawMessageSet{}
+
+	if err := set.MergeFrom(oneObjectInJSON, []jparser.MetaData{
+		{Path: "inn", ParamID: "inn"},
+		{Path: "IP.fio", ParamID: "fio"},
+	}); err != nil {
+		t.Fatalf("MergeFrom() got error = \"%v\", expected nil", err)
+	}
+
+	if err := set.MergeFrom(multipleElementsInArrayJSON, []jparser.MetaData{
+		{Path: "[].inn", ParamID: "inn"},
+		{Path: "[].IP.registrationDate", ParamID: "registration_date"},
+		{Path: "[].non-existing", ParamID: "non-existing"},
+	}); !errors.Is(err, jparser.ErrMultipleSets) {
+		t.Fatalf("MergeFrom() got error = \"%v\", expected ErrMultipleSets", err)
+	}
+
+	if err := set.MergeFrom(json.RawMessage(`{"INN": "772473497153", "ogrn": "318774600372150"}`), []jparser.MetaData{
+		{Path: "INN", ParamID: "inn"},
+		{Path: "ogrn", ParamID: "ogrn"},
+	}); err != nil {
+		t.Fatalf("MergeFrom() got error = \"%v\", expected nil", err)
+	}
+
+	expected := jparser.RawMessageSet{
+		"inn":  json.RawMessage(`"772473497153"`),
+		"fio":  json.RawMessage(`"Щербина Илья Владимирович"`),
+		"ogrn": json.RawMessage(`"318774600372150"`),
+	}
+
+	if !reflect.DeepEqual(set, expected) {
+		t.Errorf("MergeFrom() got set = %s, expected %s", set, expected)
+	}
+
+	err := set.MergeFrom(json.RawMessage(`{"ogrn": "1026605606620"}`), []jparser.MetaData{{Path: "ogrn", ParamID: "ogrn"}})
+
+	var conflictErr *jparser.ConflictError
+	if !errors.As(err, &conflictErr) || conflictErr.ParamID != "ogrn" {
+		t.Errorf("MergeFrom() got error = \"%v\", expected *ConflictError for \"ogrn\"", err)
+	}
+}
+
+func TestParseParamsSingle(t *testing.T) {
+	set, err := jparser.ParseParamsSingle(oneObjectInJSON, []jparser.MetaData{
+		{Path: "inn", ParamID: "inn"},
+		{Path: "IP.status.statusString", ParamID: "status"},
+		{Path: "contactPhones.count", ParamID: "phones_count"},
+	})
+	if err != nil {
+		t.Fatalf("ParseParamsSingle() got error = \"%v\", expected nil", err)
+	}
+
+	expected := jparser.RawMessageSet{
+		"inn":    json.RawMessage(`"772473497153"`),
+		"status": json.RawMessage(`"Действующее"`),
+	}
+
+	if !reflect.DeepEqual(set, expected) {
+		t.Errorf("ParseParamsSingle() got set = %s, expected %s", set, expected)
+	}
+
+	set, err = jparser.ParseParamsSingle(multipleElementsInArrayJSON, []jparser.MetaData{{Path: "[].inn", ParamID: "inn"}})
+	if !errors.Is(err, jparser.ErrMultipleSets) || set != nil {
+		t.Errorf("ParseParamsSingle() got set = %s, error = \"%v\", expected ErrMultipleSets", set, err)
+	}
+}
+
+func TestParseParamsJSON(t *testing.T) {
+	data := json.RawMessage(`[
+		{"inn": "6663003127", "capital": 12345678901234567890.10, "branches": [{"kpp": "667101001"}]},
+		{"inn": "7708004767", "capital": 1e400, "branches": []}
+	]`)
+
+	meta := []jparser.MetaData{
+		{Path: "[].inn", ParamID: "inn"},
+		{Path: "[].capital", ParamID: "capital"},
+		{Path: "[].branches", ParamID: "branches"},
+		{Path: "[].branches.[].kpp", ParamID: "kpp"},
+	}
+
+	result, err := jparser.ParseParamsJSON(data, meta)
+	if err != nil {
+		t.Fatalf("ParseParamsJSON() got error = \"%v\", expected nil", err)
+	}
+
+	res, err := jparser.ParseParams(data, meta)
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	expected, err := json.Marshal(res)
+	if err != nil {
+		t.Fatalf("json.Marshal() got error = \"%v\", expected nil", err)
+	}
+
+	if string(result) != string(expected) {
+		t.Errorf("ParseParamsJSON() got = %s, expected %s", result, expected)
+	}
+
+	for _, number := range []string{`"capital":12345678901234567890.10`, `"capital":1e400`} {
+		if !strings.Contains(string(result), number) {
+			t.Errorf("ParseParamsJSON() got = %s, expected it to hold %s", result, number)
+		}
+	}
+
+	if result, err := jparser.ParseParamsJSON(json.RawMessage(`[]`), meta); err != nil || string(result) != `[{}]` {
+		t.Errorf("ParseParamsJSON() got = %s, %v, expected [{}], nil", result, err)
+	}
+}
+
+func TestParseParamsOrdered(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "[].UL.legalAddress.parsedAddressRF.zipCode", ParamID: "zip"},
+		{Path: "[].inn", ParamID: "inn"},
+		{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+		{Path: "[].UL.legalAddress.date", ParamID: "address_date"},
+		{Path: "[].UL.branches.[].@", ParamID: "branch"},
+		{Path: "[].UL.legalAddress.parsedAddressRF.city.topoValue", ParamID: "city"},
+		{Path: "[].UL.branches.[].name", ParamID: "branch_name"},
+		{Path: "[].ogrn", ParamID: "{inn}"},
+	}
+
+	expectedRes, err := jparser.ParseParams(oneElementInArrayJSON, meta)
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	metaOrder := []string{"zip", "inn", "kpp", "address_date", "branch", "city", "branch_name", "6663003127"}
+
+	// Repeated, as the order of the sets underneath is random.
+	for i := 0; i < 10; i++ {
+		result, err := jparser.ParseParamsOrdered(oneElementInArrayJSON, meta)
+		if err != nil {
+			t.Fatalf("ParseParamsOrdered() got error = \"%v\", expected nil", err)
+		}
+
+		if len(result) != len(expectedRes) {
+			t.Fatalf("ParseParamsOrdered() got %d sets, expected %d", len(result), len(expectedRes))
+		}
+
+		for j, set := range result {
+			var expectedOrder []string
+
+			for _, paramID := range metaOrder {
+				if _, ok := expectedRes[j][paramID]; ok {
+					expectedOrder = append(expectedOrder, paramID)
+				}
+			}
+
+			order := make([]string, len(set))
+			for k, param := range set {
+				order[k] = param.ParamID
+
+				if !reflect.DeepEqual(param.Value, expectedRes[j][param.ParamID]) {
+					t.Errorf("ParseParamsOrdered() got %s = %s, expected %s",
+						param.ParamID, param.Value, expectedRes[j][param.ParamID])
+				}
+			}
+
+			if !reflect.DeepEqual(order, expectedOrder) {
+				t.Errorf("ParseParamsOrdered() got order = %v, expected %v", order, expectedOrder)
+			}
+		}
+
+		data, err := json.Marshal(result[0])
+		if err != nil {
+			t.Fatalf("json.Marshal() got error = \"%v\", expected nil", err)
+		}
+
+		expected := `{"zip":"620144","inn":"6663003127","kpp":"771543001","address_date":"2020-09-02",` +
+			`"branch":0,"city":"Екатеринбург","6663003127":"1026605606620"}`
+		if string(data) != expected {
+			t.Errorf("json.Marshal() got = %s, expected %s", data, expected)
+		}
+	}
+}
+
+func TestRawMessageSetHash(t *testing.T) {
+	set := jparser.RawMessageSet{
+		"inn":     json.RawMessage(`"6663003127"`),
+		"address": json.RawMessage(`{"zipCode": "620144", "regionCode": "66"}`),
+		"phones":  json.RawMessage(`[1, 2]`),
+	}
+
+	testTable := []struct {
+		name     string
+		set      jparser.RawMessageSet
+		expected bool
+	}{
+		{
+			name: "Whitespace and key order",
+			set: jparser.RawMessageSet{
+				"phones":  json.RawMessage("[ 1,\n 2 ]"),
+				"address": json.RawMessage(`{"regionCode":"66","zipCode":"620144"}`),
+				"inn":     json.RawMessage(` "6663003127" `),
+			},
+			expected: true,
+		},
+		{
+			name: "Different value",
+			set: jparser.RawMessageSet{
+				"inn":     json.RawMessage(`"6663003127"`),
+				"address": json.RawMessage(`{"zipCode": "620144", "regionCode": "77"}`),
+				"phones":  json.RawMessage(`[1, 2]`),
+			},
+		},
+		{
+			name: "Different key",
+			set: jparser.RawMessageSet{
+				"ogrn":    json.RawMessage(`"6663003127"`),
+				"address": json.RawMessage(`{"zipCode": "620144", "regionCode": "66"}`),
+				"phones":  json.RawMessage(`[1, 2]`),
+			},
+		},
+		{
+			name: "Missing param",
+			set: jparser.RawMessageSet{
+				"inn":     json.RawMessage(`"6663003127"`),
+				"address": json.RawMessage(`{"zipCode": "620144", "regionCode": "66"}`),
+			},
+		},
+	}
+
+	hash := set.Hash()
+	if len(hash) != 64 {
+		t.Fatalf("Hash() got = %q, expected 64 hex digits", hash)
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			if equal := testCase.set.Hash() == hash; equal != testCase.expected {
+				t.Errorf("Hash() got equal = %v, expected %v", equal, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestParseParamsNestSeparator(t *testing.T) {
+	data := json.RawMessage(`[
+		{"inn": "6663003127", "address": {"zip": "620017", "city": "Екатеринбург", "region": {"code": "66"}}},
+		{"inn": "7708004767", "address": {"city": "Москва"}}
+	]`)
+
+	meta := []jparser.MetaData{
+		{Path: "[].inn", ParamID: "inn"},
+		{Path: "[].address.zip", ParamID: "address.zip"},
+		{Path: "[].address.city", ParamID: "address.city"},
+		{Path: "[].address.region.code", ParamID: "address.region.code"},
+		{Path: "[].inn", ParamID: ".inn"},
+	}
+
+	result, err := jparser.ParseParamsWithOptions(data, meta, jparser.Options{NestSeparator: "."})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("json.Marshal() got error = \"%v\", expected nil", err)
+	}
+
+	expected := `[{".inn":"6663003127","address":{"city":"Екатеринбург","region":{"code":"66"},"zip":"620017"},` +
+		`"inn":"6663003127"},{".inn":"7708004767","address":{"city":"Москва"},"inn":"7708004767"}]`
+	if string(encoded) != expected {
+		t.Errorf("ParseParamsWithOptions() got = %s, expected %s", encoded, expected)
+	}
+
+	meta = append(meta, jparser.MetaData{Path: "[].address", ParamID: "address"})
+
+	result, err = jparser.ParseParamsWithOptions(data, meta[3:], jparser.Options{NestSeparator: "."})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	if _, ok := result[0]["address.region.code"]; !ok {
+		t.Errorf("ParseParamsWithOptions() got = %s, expected address.region.code kept flat beside address", result)
+	}
+}
+
+func TestParseParamsNestSeparatorKeepsHTMLCharacters(t *testing.T) {
+	data := json.RawMessage(`{"name": "A&B <x>", "address": {"city": "<Москва> & Co"}}`)
+	meta := []jparser.MetaData{
+		{Path: "name", ParamID: "company.name"},
+		{Path: "address.city", ParamID: "company.address.city"},
+	}
+
+	result, err := jparser.ParseParamsWithOptions(data, meta, jparser.Options{NestSeparator: "."})
+	if err != nil {
+		t.Fatalf("ParseParamsWithOptions() got error = \"%v\", expected nil", err)
+	}
+
+	expected := `{"address":{"city":"<Москва> & Co"},"name":"A&B <x>"}`
+	if len(result) != 1 || string(result[0]["company"]) != expected {
+		t.Errorf("ParseParamsWithOptions() got = %s, expected company = %s", result, expected)
+	}
+}
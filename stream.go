@@ -0,0 +1,370 @@
+package jparser
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// tokenDecoder is a Decoder able to step into arrays, as *json.Decoder does.
+type tokenDecoder interface {
+	Decoder
+	Token() (json.Token, error)
+}
+
+// ParseParamsFunc calls fn with every result set of ParseParams, stopping at
+// the first error returned by fn. Sets are built one root array element at a
+// time when possible, see (*Parser).ParseStream.
+func ParseParamsFunc(data json.RawMessage, meta []MetaData, fn func(RawMessageSet) error) error {
+	p, err := NewParser(meta, Options{})
+	if err != nil {
+		return err
+	}
+
+	return p.ParseStream(bytes.NewReader(data), fn)
+}
+
+// ParseParamsReader is ParseParams over the document read from r. The document
+// is buffered in full before parsing, see (*Parser).ParseStream to process
+// large arrays element by element.
+func ParseParamsReader(r io.Reader, meta []MetaData) ([]RawMessageSet, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseParams(data, meta)
+}
+
+// ParseParamsGzip is ParseParamsReader over the gzip-compressed document read
+// from r. A corrupt gzip stream fails with a *GzipError.
+func ParseParamsGzip(r io.Reader, meta []MetaData) ([]RawMessageSet, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, &GzipError{err}
+	}
+
+	defer zr.Close()
+
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, &GzipError{err}
+	}
+
+	return ParseParams(data, meta)
+}
+
+// ParseStream reads a JSON document from r and calls fn with every result
+// set, in the order ParseParams returns them. Parsing stops at the first
+// error returned by fn.
+//
+// When the document is an array, every path goes into its elements with "[]"
+// (no "#", "#1", "$", aggregates, "^" above an element or the whole array at
+// the root) and there are no Preprocess steps or JSONC, elements are decoded
+// and parsed one at a time, so memory is bounded by the largest element.
+// Otherwise, or with Options.ReverseArrays or BestEffort, the document is read
+// in full.
+func (p *Parser) ParseStream(r io.Reader, fn func(RawMessageSet) error) error {
+	return restoreParamID(p.parseStream(r, fn))
+}
+
+func (p *Parser) parseStream(r io.Reader, fn func(RawMessageSet) error) error {
+	pp := p.newRun()
+	br := bufio.NewReader(transcodeReader(r, p.opts.Encoding))
+
+	array := peekArray(br)
+	root := p.level(array)
+
+	if g := p.streamGroup(root); g != nil && array && len(p.opts.Preprocess) == 0 && !p.opts.JSONC &&
+		!p.opts.BestEffort {
+		if dec, ok := pp.codec().NewDecoder(br).(tokenDecoder); ok {
+			return p.stream(pp, dec, g, fn)
+		}
+	}
+
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return err
+	}
+
+	data, partial := p.prepareTranscoded(data)
+	if partial != nil && !errors.As(partial, new(*PartialError)) {
+		return partial
+	}
+
+	res, err := pp.parseParams(&node{data: data}, p.level(isArray(data)))
+	if err != nil {
+		return err
+	}
+
+	if err := p.emit(pp, res, fn); err != nil {
+		return err
+	}
+
+	return partial
+}
+
+// emit checks the populated params of res and passes the sets to fn.
+func (p *Parser) emit(pp *parser, res []RawMessageSet, fn func(RawMessageSet) error) error {
+	res, err := p.finish(pp, res)
+	if err != nil {
+		return err
+	}
+
+	for _, set := range res {
+		if err := fn(set); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// finish merges duplicates, checks the populated and required params of res
+// and nests them.
+func (p *Parser) finish(pp *parser, res []RawMessageSet) ([]RawMessageSet, error) {
+	if err := pp.collected(); err != nil {
+		return nil, err
+	}
+
+	res, err := pp.checkPopulated(p.mergeDuplicates(res), p.meta)
+	if err != nil {
+		return nil, err
+	}
+
+	if res, err = pp.distinct(res); err != nil {
+		return nil, err
+	}
+
+	if err := pp.checkRequiredSets(res, p.meta); err != nil {
+		return nil, err
+	}
+
+	pp.finished += len(res)
+
+	if p.opts.NestSeparator == "" {
+		return res, nil
+	}
+
+	for i, set := range res {
+		if res[i], err = nest(set, p.opts.NestSeparator); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// streamGroup returns the "[]" group of root if the result sets can be built
+// element by element.
+func (p *Parser) streamGroup(root *level) *group {
+	if len(root.groups) != 1 || p.opts.ReverseArrays {
+		return nil
+	}
+
+	g := root.groups[0]
+	if g.segment != "[]" || g.all != nil || g.count != nil || g.singleton != nil || g.last != nil ||
+		len(g.aggregates) > 0 || climbsOut(g.base, nil) {
+		return nil
+	}
+
+	return g
+}
+
+// climbsOut reports whether a "^" of lvl, reached through the segments of
+// stack below a root array element, climbs above that element, which the
+// stream doesn't hold. An entry of stack is true for an array element, from
+// which "^" skips the array, see enclosing.
+func climbsOut(lvl *level, stack []bool) bool {
+	for _, g := range lvl.groups {
+		below := func(element bool) []bool {
+			return append(append([]bool(nil), stack...), element)
+		}
+
+		switch {
+		case g.segment == "^":
+			up := len(stack) - 1
+			if up >= 0 && stack[up] {
+				up--
+			}
+
+			if up < 0 || climbsOut(g.next, stack[:up]) {
+				return true
+			}
+		case g.segment == "**":
+			// The descent may match the node it starts from.
+			if climbsOut(g.next, stack) {
+				return true
+			}
+		case g.segment == "[]" || g.filter != nil || g.window != nil:
+			if climbsOut(g.base, below(true)) {
+				return true
+			}
+
+			for _, a := range g.aggregates {
+				if climbsOut(a.next, below(true)) {
+					return true
+				}
+			}
+		case g.segment == "*":
+			if climbsOut(g.base, below(false)) {
+				return true
+			}
+		case g.next != nil:
+			if climbsOut(g.next, below(g.element != nil || g.segment == "[*]")) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// stream parses the elements of the root array one at a time.
+func (p *Parser) stream(pp *parser, dec tokenDecoder, g *group, fn func(RawMessageSet) error) error {
+	s, err := p.newElementStream(pp, dec, g)
+	if err != nil {
+		return err
+	}
+
+	for {
+		res, ok, err := s.next()
+		if err != nil || !ok {
+			return err
+		}
+
+		for _, set := range res {
+			if err := fn(set); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// elementStream parses the elements of a root array iterated by the "[]"
+// group g one at a time.
+type elementStream struct {
+	p    *Parser
+	pp   *parser
+	dec  tokenDecoder
+	g    *group
+	root *node
+	i    int
+	// produced is the number of elements that had result sets.
+	produced int
+	done     bool
+	// rows is the number of result sets yielded, see Options.MaxRows.
+	rows int
+}
+
+func (p *Parser) newElementStream(pp *parser, dec tokenDecoder, g *group) (*elementStream, error) {
+	if _, err := dec.Token(); err != nil {
+		return nil, &UnmarshalError{err: err, paramID: g.meta[0].ParamID}
+	}
+
+	return &elementStream{p: p, pp: pp, dec: dec, g: g, root: &node{}}, nil
+}
+
+// next returns the checked result sets of the next element, or false once
+// the array is exhausted. An array whose elements have no sets, or none at
+// all, yields the sets of ParseParams.
+func (s *elementStream) next() ([]RawMessageSet, bool, error) {
+	if s.done {
+		return nil, false, nil
+	}
+
+	for s.dec.More() {
+		var element json.RawMessage
+		if err := s.dec.Decode(&element); err != nil {
+			return nil, false, &UnmarshalError{err: err, paramID: s.g.meta[0].ParamID}
+		}
+
+		if limit := s.p.opts.MaxArrayElements; limit > 0 && s.i >= limit {
+			continue
+		}
+
+		res, err := s.pp.parseElement(s.root.child(element, indexSegment(s.i)), s.i, "", s.g)
+		if err != nil {
+			return nil, false, err
+		}
+
+		s.i++
+
+		if len(res) > 0 {
+			s.produced++
+		}
+
+		if res, err = s.p.finish(s.pp, res); err != nil {
+			return nil, false, err
+		}
+
+		if s.rows += len(res); s.p.opts.MaxRows > 0 && s.rows > s.p.opts.MaxRows {
+			return nil, false, ErrTooManyRows
+		}
+
+		return res, true, nil
+	}
+
+	s.done = true
+
+	if _, err := s.dec.Token(); err != nil {
+		return nil, false, &UnmarshalError{err: err, paramID: s.g.meta[0].ParamID}
+	}
+
+	if s.p.opts.DisallowTrailingData {
+		if err := trailingData(s.dec); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if s.produced > 0 {
+		return nil, false, nil
+	}
+
+	// Like parseParams, elements without sets yield a single empty set.
+	res := []RawMessageSet{{}}
+
+	if s.i == 0 {
+		if err := s.pp.checkRequired(s.g.base.meta, s.root.child(nil, s.g.segment)); err != nil {
+			return nil, false, err
+		}
+
+		if s.g.index != nil {
+			if err := s.pp.checkRequired([]MetaData{*s.g.index}, s.root.child(nil, s.g.segment)); err != nil {
+				return nil, false, err
+			}
+		}
+
+		res = []RawMessageSet{s.pp.unresolved(s.g.base.meta, s.root)}
+	}
+
+	res, err := s.p.finish(s.pp, res)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return res, true, nil
+}
+
+// peekArray reports whether the next non-space byte in br opens an array,
+// leaving it unread.
+func peekArray(br *bufio.Reader) bool {
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return false
+		}
+
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+
+		_ = br.UnreadByte()
+
+		return c == '['
+	}
+}
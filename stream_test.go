@@ -0,0 +1,443 @@
+package jparser_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+var streamMeta = []jparser.MetaData{
+	{Path: "[].inn", ParamID: "inn"},
+	{Path: "[].@", ParamID: "index"},
+	{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+	{Path: "[].UL.branches.[].#", ParamID: "branches"},
+}
+
+// syntheticArray returns an array of n companies with i%3 branches each.
+func syntheticArray(n int) json.RawMessage {
+	var buf bytes.Buffer
+
+	buf.WriteString("[\n")
+
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteString(",\n")
+		}
+
+		fmt.Fprintf(&buf, `{"inn":"%010d","UL":{"branches":[`, i)
+
+		for j := 0; j < i%3; j++ {
+			if j > 0 {
+				buf.WriteString(",")
+			}
+
+			fmt.Fprintf(&buf, `{"kpp":"%d-%d"}`, i, j)
+		}
+
+		buf.WriteString("]}}")
+	}
+
+	buf.WriteString("\n]")
+
+	return buf.Bytes()
+}
+
+func TestParseStream(t *testing.T) {
+	testTable := []struct {
+		name string
+		data json.RawMessage
+		meta []jparser.MetaData
+		opts jparser.Options
+	}{
+		{
+			name: "large array",
+			data: syntheticArray(10000),
+			meta: streamMeta,
+		},
+		{
+			name: "empty array",
+			data: json.RawMessage(` [] `),
+			meta: streamMeta,
+		},
+		{
+			name: "empty array with a default",
+			data: json.RawMessage(`[]`),
+			meta: []jparser.MetaData{{Path: "[].inn", ParamID: "inn", Default: json.RawMessage(`"d"`)}},
+		},
+		{
+			name: "empty array with exists",
+			data: json.RawMessage(`[]`),
+			meta: []jparser.MetaData{{Path: "[].inn.&exists", ParamID: "has_inn"}},
+		},
+		{
+			name: "empty array with provenance",
+			data: json.RawMessage(`[]`),
+			meta: []jparser.MetaData{{Path: "[].inn", ParamID: "inn"}},
+			opts: jparser.Options{ProvenanceSuffix: "_provenance"},
+		},
+		{
+			name: "array count at the root is read in full",
+			data: syntheticArray(10),
+			meta: append([]jparser.MetaData{{Path: "[].#", ParamID: "count"}}, streamMeta...),
+		},
+		{
+			name: "skipped scalars",
+			data: json.RawMessage(`[1, "a", null]`),
+			meta: streamMeta,
+			opts: jparser.Options{MixedArrays: jparser.MixedSkip},
+		},
+		{
+			name: "skipped scalars beside an object",
+			data: json.RawMessage(`[1, {"inn": "1"}, 2]`),
+			meta: streamMeta,
+			opts: jparser.Options{MixedArrays: jparser.MixedSkip},
+		},
+		{
+			name: "climbing to the root array is read in full",
+			data: syntheticArray(10),
+			meta: []jparser.MetaData{
+				{Path: "[].inn", ParamID: "inn"},
+				{Path: "[].^.#", ParamID: "count"},
+				{Path: "[].UL.branches.[].^.^.^", ParamID: "root"},
+			},
+		},
+		{
+			name: "climbing within the element",
+			data: syntheticArray(10),
+			meta: []jparser.MetaData{{Path: "[].UL.branches.[].^.^.inn", ParamID: "inn"}},
+		},
+		{
+			name: "object root is read in full",
+			data: oneObjectInJSON,
+			meta: []jparser.MetaData{{Path: "inn", ParamID: "inn"}},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			p, err := jparser.NewParser(testCase.meta, testCase.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			expected, err := p.ParseParams(testCase.data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var res []jparser.RawMessageSet
+
+			err = p.ParseStream(bytes.NewReader(testCase.data), func(set jparser.RawMessageSet) error {
+				res = append(res, set)
+
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(res, expected) {
+				t.Errorf("streamed %d sets, expected %d sets equal to ParseParams", len(res), len(expected))
+			}
+		})
+	}
+}
+
+func TestParseStreamStopsOnCallbackError(t *testing.T) {
+	p, err := jparser.NewParser(streamMeta, jparser.Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	errStop := errors.New("stop")
+	calls := 0
+
+	err = p.ParseStream(bytes.NewReader(syntheticArray(100)), func(jparser.RawMessageSet) error {
+		calls++
+
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("expected the callback error, got: %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected 1 call, got: %d", calls)
+	}
+}
+
+func TestParseStreamSyntaxError(t *testing.T) {
+	p, err := jparser.NewParser(streamMeta, jparser.Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = p.ParseStream(bytes.NewReader([]byte(`[{"inn":"1"},{"inn":`)), func(jparser.RawMessageSet) error {
+		return nil
+	})
+
+	var unmarshalErr *jparser.UnmarshalError
+	if !errors.As(err, &unmarshalErr) {
+		t.Errorf("expected *UnmarshalError, got: %v", err)
+	}
+}
+
+func TestParseParamsFunc(t *testing.T) {
+	testTable := []struct {
+		name string
+		data json.RawMessage
+		meta []jparser.MetaData
+	}{
+		{
+			name: "streamed array",
+			data: syntheticArray(100),
+			meta: streamMeta,
+		},
+		{
+			name: "array read in full",
+			data: multipleElementsInArrayJSON,
+			meta: []jparser.MetaData{
+				{Path: "[].inn", ParamID: "inn"},
+				{Path: "[].UL.branches.[].kpp", ParamID: "kpp"},
+				{Path: "[].#", ParamID: "count"},
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			expected, err := jparser.ParseParams(testCase.data, testCase.meta)
+			if err != nil {
+				t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+			}
+
+			var res []jparser.RawMessageSet
+
+			if err := jparser.ParseParamsFunc(testCase.data, testCase.meta, func(set jparser.RawMessageSet) error {
+				res = append(res, set)
+
+				return nil
+			}); err != nil {
+				t.Fatalf("ParseParamsFunc() got error = \"%v\", expected nil", err)
+			}
+
+			if !reflect.DeepEqual(res, expected) {
+				t.Errorf("ParseParamsFunc() got result = %v, expected %v", res, expected)
+			}
+		})
+	}
+}
+
+func TestParseParamsFuncStopsOnCallbackError(t *testing.T) {
+	errStop := errors.New("stop")
+	calls := 0
+
+	err := jparser.ParseParamsFunc(syntheticArray(100), streamMeta, func(jparser.RawMessageSet) error {
+		if calls++; calls == 50 {
+			return errStop
+		}
+
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("ParseParamsFunc() got error = \"%v\", expected the callback error", err)
+	}
+
+	if calls != 50 {
+		t.Errorf("ParseParamsFunc() made %d calls, expected 50", calls)
+	}
+}
+
+func TestParseParamsReader(t *testing.T) {
+	testTable := []struct {
+		name string
+		data json.RawMessage
+		meta []jparser.MetaData
+	}{
+		{
+			name: "object",
+			data: oneObjectInJSON,
+			meta: []jparser.MetaData{{Path: "inn", ParamID: "inn"}, {Path: "IP.fio", ParamID: "fio"}},
+		},
+		{
+			name: "array",
+			data: multipleElementsInArrayJSON,
+			meta: []jparser.MetaData{{Path: "[].inn", ParamID: "inn"}, {Path: "[].UL.branches.[].kpp", ParamID: "kpp"}},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			expected, err := jparser.ParseParams(testCase.data, testCase.meta)
+			if err != nil {
+				t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+			}
+
+			res, err := jparser.ParseParamsReader(strings.NewReader(string(testCase.data)), testCase.meta)
+			if err != nil {
+				t.Fatalf("ParseParamsReader() got error = \"%v\", expected nil", err)
+			}
+
+			if !reflect.DeepEqual(res, expected) {
+				t.Errorf("ParseParamsReader() got result = %v, expected %v", res, expected)
+			}
+		})
+	}
+}
+
+func TestParseParamsReaderTruncated(t *testing.T) {
+	data := string(multipleElementsInArrayJSON)
+
+	res, err := jparser.ParseParamsReader(strings.NewReader(data[:len(data)/2]), []jparser.MetaData{
+		{Path: "[].inn", ParamID: "inn"},
+	})
+
+	var unmarshalErr *jparser.UnmarshalError
+	if !errors.As(err, &unmarshalErr) || res != nil {
+		t.Errorf("ParseParamsReader() got result = %v, error = \"%v\", expected *UnmarshalError", res, err)
+	}
+}
+
+func TestParseParamsGzip(t *testing.T) {
+	meta := []jparser.MetaData{{Path: "[].inn", ParamID: "inn"}, {Path: "[].UL.branches.[].kpp", ParamID: "kpp"}}
+
+	var buf bytes.Buffer
+
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(multipleElementsInArrayJSON); err != nil {
+		t.Fatalf("Write() got error = \"%v\", expected nil", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close() got error = \"%v\", expected nil", err)
+	}
+
+	compressed := buf.Bytes()
+
+	expected, err := jparser.ParseParams(multipleElementsInArrayJSON, meta)
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	res, err := jparser.ParseParamsGzip(bytes.NewReader(compressed), meta)
+	if err != nil {
+		t.Fatalf("ParseParamsGzip() got error = \"%v\", expected nil", err)
+	}
+
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("ParseParamsGzip() got result = %v, expected %v", res, expected)
+	}
+
+	corrupt := append([]byte{}, compressed...)
+	corrupt[len(corrupt)-5] ^= 0xff
+
+	testTable := []struct {
+		name string
+		data []byte
+	}{
+		{name: "not gzip", data: multipleElementsInArrayJSON},
+		{name: "checksum", data: corrupt},
+		{name: "truncated", data: compressed[:len(compressed)/2]},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			res, err := jparser.ParseParamsGzip(bytes.NewReader(testCase.data), meta)
+
+			var gzipErr *jparser.GzipError
+			if !errors.As(err, &gzipErr) || res != nil {
+				t.Errorf("ParseParamsGzip() got result = %v, error = \"%v\", expected *GzipError", res, err)
+			}
+		})
+	}
+}
+
+func TestParseStreamUnderpopulated(t *testing.T) {
+	data := json.RawMessage(`[{"inn": "1", "ogrn": "1"}, {"inn": "2", "ogrn": "2"}, {"inn": "3"}]`)
+	meta := []jparser.MetaData{{Path: "[].inn", ParamID: "inn"}, {Path: "[].ogrn", ParamID: "ogrn"}}
+
+	p, err := jparser.NewParser(meta, jparser.Options{MinPopulatedParams: 2})
+	if err != nil {
+		t.Fatalf("NewParser() got error = \"%v\", expected nil", err)
+	}
+
+	_, expectedErr := p.ParseParams(data)
+
+	err = p.ParseStream(bytes.NewReader(data), func(jparser.RawMessageSet) error { return nil })
+	if !reflect.DeepEqual(err, expectedErr) {
+		t.Errorf("ParseStream() got error = \"%v\", expected \"%v\"", err, expectedErr)
+	}
+
+	var populatedErr *jparser.UnderpopulatedError
+	if !errors.As(err, &populatedErr) || populatedErr.Index != 2 {
+		t.Errorf("ParseStream() got error = \"%v\", expected *UnderpopulatedError for set 2", err)
+	}
+}
+
+func TestParseStreamMaxRows(t *testing.T) {
+	p, err := jparser.NewParser([]jparser.MetaData{{Path: "[].inn", ParamID: "inn"}}, jparser.Options{MaxRows: 2})
+	if err != nil {
+		t.Fatalf("NewParser() got error = \"%v\", expected nil", err)
+	}
+
+	rows := 0
+
+	err = p.ParseStream(strings.NewReader(`[{"inn": "1"}, {"inn": "2"}, {"inn": "3"}]`),
+		func(jparser.RawMessageSet) error {
+			rows++
+
+			return nil
+		})
+	if rows != 2 || !errors.Is(err, jparser.ErrTooManyRows) {
+		t.Errorf("ParseStream() got %d rows and error = \"%v\", expected 2 rows and ErrTooManyRows", rows, err)
+	}
+}
+
+func TestParseStreamBestEffort(t *testing.T) {
+	meta := []jparser.MetaData{{Path: "[].inn", ParamID: "inn"}}
+
+	p, err := jparser.NewParser(meta, jparser.Options{BestEffort: true})
+	if err != nil {
+		t.Fatalf("NewParser() got error = \"%v\", expected nil", err)
+	}
+
+	testCases := []struct {
+		name    string
+		data    json.RawMessage
+		partial bool
+	}{
+		{name: "Truncated array", data: json.RawMessage(`[{"inn": "1"}, {"inn": "2"}, {"inn": `), partial: true},
+		{name: "Malformed element", data: json.RawMessage(`[{"inn": "1"}, {"inn" "2"}]`), partial: true},
+		{name: "Valid", data: json.RawMessage(`[{"inn": "1"}, {"inn": "2"}]`)},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			expected, expectedErr := p.ParseParams(testCase.data)
+
+			var res []jparser.RawMessageSet
+
+			err := p.ParseStream(bytes.NewReader(testCase.data), func(set jparser.RawMessageSet) error {
+				res = append(res, set)
+
+				return nil
+			})
+
+			var partialErr *jparser.PartialError
+			if testCase.partial != errors.As(err, &partialErr) || !reflect.DeepEqual(err, expectedErr) {
+				t.Fatalf("ParseStream() got error = \"%v\", expected \"%v\"", err, expectedErr)
+			}
+
+			if !reflect.DeepEqual(res, expected) {
+				t.Errorf("ParseStream() got %s, expected %s", res, expected)
+			}
+		})
+	}
+}
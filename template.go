@@ -0,0 +1,85 @@
+package jparser
+
+import "strings"
+
+// isTemplate reports whether paramID references fields as "{path}".
+func isTemplate(paramID string) bool {
+	i := strings.IndexByte(paramID, '{')
+
+	return i >= 0 && strings.IndexByte(paramID[i:], '}') > 0
+}
+
+// expandTemplate substitutes every "{path}" of paramID with the value at
+// path in the object holding n. Strings are substituted unquoted, other
+// values as compact JSON.
+func (p *parser) expandTemplate(n *node, paramID string) (string, error) {
+	var b strings.Builder
+
+	for rest := paramID; ; {
+		start := strings.IndexByte(rest, '{')
+		end := strings.IndexByte(rest, '}')
+
+		if start < 0 || end < start {
+			b.WriteString(rest)
+			break
+		}
+
+		b.WriteString(rest[:start])
+
+		value, ok := p.reference(n.parent, rest[start+1:end])
+		if !ok {
+			return "", &TemplateError{paramID, n.path, "reference " + rest[start:end+1] + " not found"}
+		}
+
+		b.WriteString(value)
+
+		rest = rest[end+1:]
+	}
+
+	res := b.String()
+	if p.declared[res] {
+		return "", &TemplateError{paramID, n.path, "expands to declared param_id " + res}
+	}
+
+	return res, nil
+}
+
+// reference returns the value at the key path in the object n.
+func (p *parser) reference(n *node, path string) (string, bool) {
+	if n == nil {
+		return "", false
+	}
+
+	object, err := p.object(n)
+	if err != nil {
+		return "", false
+	}
+
+	for {
+		var key string
+
+		key, path = splitPath(path)
+		key = unescapeKey(key)
+
+		value, ok := object[key]
+		if !ok {
+			return "", false
+		}
+
+		if path == "" {
+			var s string
+			if err := p.codec().Unmarshal(value, &s); err == nil {
+				return s, true
+			}
+
+			canonical, err := canonicalJSON(value)
+
+			return string(canonical), err == nil
+		}
+
+		object = nil
+		if err := p.codec().Unmarshal(value, &object); err != nil {
+			return "", false
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package jparser_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestParseParamsTemplateParamID(t *testing.T) {
+	meta := []jparser.MetaData{
+		{Path: "[].inn", ParamID: "inn"},
+		{Path: "[].UL.branches.[].kpp", ParamID: "kpp_{parsedAddressRF.regionCode}"},
+	}
+
+	result, err := jparser.ParseParams(oneElementInArrayJSON, meta)
+	if err != nil {
+		t.Fatalf("ParseParams() got error = \"%v\", expected nil", err)
+	}
+
+	inn := json.RawMessage(`"6663003127"`)
+	expected := []jparser.RawMessageSet{
+		{"inn": inn, "kpp_77": json.RawMessage(`"771543001"`)},
+		{"inn": inn, "kpp_77": json.RawMessage(`"771543002"`)},
+		{"inn": inn, "kpp_78": json.RawMessage(`"780243001"`)},
+		{"inn": inn, "kpp_59": json.RawMessage(`"590443001"`)},
+		{"inn": inn, "kpp_74": json.RawMessage(`"745343002"`)},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("ParseParams() got result = %v, expected %v", result, expected)
+	}
+}
+
+func TestParseParamsTemplateParamIDErrors(t *testing.T) {
+	testTable := []struct {
+		name string
+		meta []jparser.MetaData
+	}{
+		{
+			name: "Missing reference",
+			meta: []jparser.MetaData{
+				{Path: "[].UL.branches.[].kpp", ParamID: "kpp_{parsedAddressRF.non-existing}"},
+			},
+		},
+		{
+			name: "Collision with a declared param",
+			meta: []jparser.MetaData{
+				{Path: "[].UL.kpp", ParamID: "kpp_77"},
+				{Path: "[].UL.branches.[].kpp", ParamID: "kpp_{parsedAddressRF.regionCode}"},
+			},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := jparser.ParseParams(oneElementInArrayJSON, test.meta)
+
+			var templateErr *jparser.TemplateError
+			if !errors.As(err, &templateErr) {
+				t.Fatalf("ParseParams() got error = \"%v\", expected *TemplateError", err)
+			}
+
+			if templateErr.ParamID != test.meta[len(test.meta)-1].ParamID {
+				t.Errorf("TemplateError.ParamID = %s, expected %s", templateErr.ParamID, test.meta[len(test.meta)-1].ParamID)
+			}
+		})
+	}
+}
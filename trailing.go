@@ -0,0 +1,46 @@
+package jparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+var ErrTrailingData = errors.New("trailing data after JSON value")
+
+// checkTrailing returns ErrTrailingData if data holds more than one JSON
+// value and Options.DisallowTrailingData is set. Malformed values are left
+// for the parser to report.
+func (p *Parser) checkTrailing(data json.RawMessage) error {
+	if !p.opts.DisallowTrailingData || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	dec := p.newRun().codec().NewDecoder(bytes.NewReader(data))
+
+	var value json.RawMessage
+	if err := dec.Decode(&value); err != nil {
+		return nil // nolint:nilerr // malformed input is reported by the parser
+	}
+
+	return trailingData(dec)
+}
+
+// trailingData returns ErrTrailingData if dec holds anything but whitespace
+// after the JSON value read from it.
+func trailingData(dec Decoder) error {
+	if dec, ok := dec.(tokenDecoder); ok {
+		if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+			return ErrTrailingData
+		}
+
+		return nil
+	}
+
+	if dec.More() {
+		return ErrTrailingData
+	}
+
+	return nil
+}
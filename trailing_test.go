@@ -0,0 +1,55 @@
+package jparser_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/egelis/jparser"
+)
+
+func TestParseParamsDisallowTrailingData(t *testing.T) {
+	meta := []jparser.MetaData{{Path: "[].inn", ParamID: "inn"}}
+
+	testTable := []struct {
+		name        string
+		data        json.RawMessage
+		expectedErr error
+	}{
+		{
+			name: "Trailing whitespace",
+			data: json.RawMessage("[{\"inn\": \"6663003127\"}]\n\t "),
+		},
+		{
+			name:        "Concatenated values",
+			data:        json.RawMessage(`[{"inn": "6663003127"}][{"inn": "7736207543"}]`),
+			expectedErr: jparser.ErrTrailingData,
+		},
+		{
+			name:        "Trailing junk",
+			data:        json.RawMessage(`[{"inn": "6663003127"}]}garbage`),
+			expectedErr: jparser.ErrTrailingData,
+		},
+	}
+
+	opts := jparser.Options{DisallowTrailingData: true}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := jparser.ParseParamsWithOptions(test.data, meta, opts); !errors.Is(err, test.expectedErr) {
+				t.Errorf("ParseParamsWithOptions() got error = \"%v\", expected %v", err, test.expectedErr)
+			}
+
+			p, err := jparser.NewParser(meta, opts)
+			if err != nil {
+				t.Fatalf("NewParser() got error = \"%v\", expected nil", err)
+			}
+
+			err = p.ParseStream(bytes.NewReader(test.data), func(jparser.RawMessageSet) error { return nil })
+			if !errors.Is(err, test.expectedErr) {
+				t.Errorf("ParseStream() got error = \"%v\", expected %v", err, test.expectedErr)
+			}
+		})
+	}
+}